@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	"context"
 	"testing"
 
 	"gorm.io/driver/sqlite"
@@ -10,14 +11,35 @@ import (
 	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
 )
 
+// SQLiteDialector is the SQLite gorm dialector with the tag filter the
+// repository needs in place of Postgres' jsonb containment.
+type SQLiteDialector struct {
+	*sqlite.Dialector
+}
+
+var _ vault.TagFilterDialector = SQLiteDialector{}
+
+// SQLite opens dsn like sqlite.Open.
+func SQLite(dsn string) SQLiteDialector {
+	return SQLiteDialector{sqlite.Open(dsn).(*sqlite.Dialector)}
+}
+
+func (SQLiteDialector) WhereTag(tx *gorm.DB, column, k, v string) *gorm.DB {
+	return tx.Where("EXISTS (SELECT 1 FROM json_each(CAST("+column+" AS TEXT)) WHERE json_each.key = ? AND json_each.value = ?)", k, v)
+}
+
 func NewDB(t *testing.T, dsn string) *gorm.DB {
 	t.Helper()
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	db, err := gorm.Open(SQLite(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
 	if err != nil {
 		t.Fatalf("open sqlite: %v", err)
 	}
-	if err := db.AutoMigrate(&vault.SecretRecord{}); err != nil {
-		t.Fatalf("automigrate: %v", err)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	if err := vault.Migrate(context.Background(), sqlDB, "secret_records"); err != nil {
+		t.Fatalf("migrate: %v", err)
 	}
 	return db
 }
@@ -2,6 +2,7 @@ package fakes
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"reflect"
 	"sync"
@@ -9,8 +10,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 )
 
-// KMS is a test double for vault.KMSAPI.
-// It returns Plaintext and records/validates inputs.
+// KMS is a test double for vault.KMSWriteAPI.
+// It returns Plaintext and records/validates inputs. GenerateDataKey hands out
+// Plaintext as the data key (generating a random one if unset), so a DEK
+// produced by GenerateDataKey decrypts again through Decrypt.
 type KMS struct {
 	mu sync.Mutex
 
@@ -21,6 +24,13 @@ type KMS struct {
 
 	Calls     int
 	LastInput *kms.DecryptInput
+
+	GenerateCalls     int
+	LastGenerateInput *kms.GenerateDataKeyInput
+
+	ReEncryptErr       error // if set, ReEncrypt returns this error
+	ReEncryptCalls     int
+	LastReEncryptInput *kms.ReEncryptInput
 }
 
 func (f *KMS) Decrypt(ctx context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
@@ -44,5 +54,74 @@ func (f *KMS) Decrypt(ctx context.Context, in *kms.DecryptInput, _ ...func(*kms.
 		}
 	}
 	// CiphertextBlob is already bytes (provider base64-decodes before calling KMS).
-	return &kms.DecryptOutput{Plaintext: f.Plaintext}, nil
+	// Like the SDK, every call returns a fresh buffer the caller may wipe.
+	return &kms.DecryptOutput{Plaintext: append([]byte(nil), f.Plaintext...)}, nil
+}
+
+func (f *KMS) GenerateDataKey(ctx context.Context, in *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.GenerateCalls++
+	f.LastGenerateInput = in
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.ExpectKeyID != "" {
+		if in.KeyId == nil || *in.KeyId != f.ExpectKeyID {
+			return nil, errors.New("unexpected KeyId")
+		}
+	}
+	if f.ExpectEncCtx != nil {
+		if !reflect.DeepEqual(f.ExpectEncCtx, in.EncryptionContext) {
+			return nil, errors.New("unexpected EncryptionContext")
+		}
+	}
+	if f.Plaintext == nil {
+		f.Plaintext = make([]byte, 32)
+		if _, err := rand.Read(f.Plaintext); err != nil {
+			return nil, err
+		}
+	}
+	dek := append([]byte(nil), f.Plaintext...)
+	return &kms.GenerateDataKeyOutput{
+		Plaintext:      dek,
+		CiphertextBlob: append([]byte("WRAPPED:"), dek...),
+		KeyId:          in.KeyId,
+	}, nil
+}
+
+// ReEncrypt validates the source side like Decrypt and returns the blob
+// prefixed with "REWRAPPED:<destination key id>:".
+func (f *KMS) ReEncrypt(ctx context.Context, in *kms.ReEncryptInput, _ ...func(*kms.Options)) (*kms.ReEncryptOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ReEncryptCalls++
+	f.LastReEncryptInput = in
+
+	if f.ReEncryptErr != nil {
+		return nil, f.ReEncryptErr
+	}
+	if in.DestinationKeyId == nil {
+		return nil, errors.New("missing DestinationKeyId")
+	}
+	if f.ExpectKeyID != "" {
+		if in.SourceKeyId == nil || *in.SourceKeyId != f.ExpectKeyID {
+			return nil, errors.New("unexpected SourceKeyId")
+		}
+	}
+	if f.ExpectEncCtx != nil {
+		if !reflect.DeepEqual(f.ExpectEncCtx, in.SourceEncryptionContext) ||
+			!reflect.DeepEqual(f.ExpectEncCtx, in.DestinationEncryptionContext) {
+			return nil, errors.New("unexpected EncryptionContext")
+		}
+	}
+	blob := append([]byte("REWRAPPED:"+*in.DestinationKeyId+":"), in.CiphertextBlob...)
+	return &kms.ReEncryptOutput{
+		CiphertextBlob: blob,
+		KeyId:          in.DestinationKeyId,
+		SourceKeyId:    in.SourceKeyId,
+	}, nil
 }
@@ -0,0 +1,66 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// SecretsManager is a test double for vault.SecretsManagerAPI.
+// Versions holds secret id -> version id -> SecretString; Stages holds
+// secret id -> staging label -> version id. A request naming neither a
+// VersionId nor a VersionStage reads AWSCURRENT.
+type SecretsManager struct {
+	mu sync.Mutex
+
+	Versions map[string]map[string]string
+	Stages   map[string]map[string]string
+	Err      error
+
+	Calls     int
+	LastInput *secretsmanager.GetSecretValueInput
+}
+
+func (f *SecretsManager) GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if in == nil || in.SecretId == nil {
+		return nil, errors.New("missing SecretId")
+	}
+
+	id := *in.SecretId
+	f.Calls++
+	f.LastInput = in
+
+	notFound := &types.ResourceNotFoundException{Message: &id}
+	versionID := ""
+	if in.VersionId != nil {
+		versionID = *in.VersionId
+	} else {
+		stage := "AWSCURRENT"
+		if in.VersionStage != nil {
+			stage = *in.VersionStage
+		}
+		v, ok := f.Stages[id][stage]
+		if !ok {
+			return nil, notFound
+		}
+		versionID = v
+	}
+	val, ok := f.Versions[id][versionID]
+	if !ok {
+		return nil, notFound
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		Name:         &id,
+		SecretString: &val,
+		VersionId:    &versionID,
+	}, nil
+}
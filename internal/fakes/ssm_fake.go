@@ -3,22 +3,29 @@ package fakes
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
 
-// SSM is a test double for vault.SSMAPI.
-// Values holds parameter name -> value (string). WithDecryption is ignored.
+// SSM is a test double for vault.SSMWriteAPI.
+// Values holds parameter name -> latest value (string). PutParameter also
+// keeps every version, readable with a "name:version" selector; a value
+// seeded directly in Values counts as version 1. WithDecryption is ignored.
 type SSM struct {
 	mu sync.Mutex
 
-	Values map[string]string
-	Err    error
+	Values   map[string]string
+	versions map[string][]string
+	Err      error
 
 	Calls    int
 	LastName string
+
+	PutCalls int
 }
 
 func (f *SSM) GetParameter(ctx context.Context, in *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
@@ -37,8 +44,18 @@ func (f *SSM) GetParameter(ctx context.Context, in *ssm.GetParameterInput, _ ...
 	f.LastName = name
 
 	val, ok := f.Values[name]
+	if base, sel, found := strings.Cut(name, ":"); found {
+		val, ok = "", false
+		_, exists := f.Values[base]
+		if n, err := strconv.Atoi(sel); err == nil && exists && n >= 1 && n <= len(f.history(base)) {
+			val, ok = f.history(base)[n-1], true
+		}
+		if !ok {
+			return nil, &types.ParameterVersionNotFound{Message: &name}
+		}
+	}
 	if !ok {
-		return nil, errors.New("parameter not found")
+		return nil, &types.ParameterNotFound{Message: &name}
 	}
 	return &ssm.GetParameterOutput{
 		Parameter: &types.Parameter{
@@ -47,3 +64,62 @@ func (f *SSM) GetParameter(ctx context.Context, in *ssm.GetParameterInput, _ ...
 		},
 	}, nil
 }
+
+func (f *SSM) PutParameter(ctx context.Context, in *ssm.PutParameterInput, _ ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if in == nil || in.Name == nil || in.Value == nil {
+		return nil, errors.New("missing Name or Value")
+	}
+
+	name := *in.Name
+	f.PutCalls++
+	f.LastName = name
+
+	if _, ok := f.Values[name]; ok && (in.Overwrite == nil || !*in.Overwrite) {
+		return nil, errors.New("parameter already exists")
+	}
+	if f.Values == nil {
+		f.Values = map[string]string{}
+	}
+	if f.versions == nil {
+		f.versions = map[string][]string{}
+	}
+	f.versions[name] = append(f.history(name), *in.Value)
+	f.Values[name] = *in.Value
+	return &ssm.PutParameterOutput{Version: int64(len(f.versions[name]))}, nil
+}
+
+// history returns the versions of name, oldest first. Callers hold f.mu.
+func (f *SSM) history(name string) []string {
+	if h := f.versions[name]; len(h) > 0 {
+		return h
+	}
+	if v, ok := f.Values[name]; ok {
+		return []string{v}
+	}
+	return nil
+}
+
+func (f *SSM) DeleteParameter(ctx context.Context, in *ssm.DeleteParameterInput, _ ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if in == nil || in.Name == nil {
+		return nil, errors.New("missing Name")
+	}
+	name := *in.Name
+	if _, ok := f.Values[name]; !ok {
+		return nil, &types.ParameterNotFound{Message: &name}
+	}
+	delete(f.Values, name)
+	delete(f.versions, name)
+	return &ssm.DeleteParameterOutput{}, nil
+}
@@ -1,6 +1,10 @@
 package vault
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
 
 // MakeAADEndEncCtx is a function creating Additional Authentication Data (AAD)
 // and create encryption context - a set of non-secret that are cryptographically
@@ -20,3 +24,53 @@ func MakeAADAndEncCtx(tenantID uuid.UUID, key string) ([]byte, map[string]string
 	}
 	return aad, encCtx
 }
+
+// AAD schemes recorded in SecretRecord.AADScheme. They decide what the AEAD AAD
+// and the KMS EncryptionContext of a record are bound to.
+const (
+	// AADSchemeV1 binds tenant_id and key (MakeAADAndEncCtx). Records with
+	// an empty AADScheme use it.
+	AADSchemeV1 = "aad_v1"
+	// AADSchemeV2 also binds the record's ID, environment, store and
+	// version, so those columns cannot be edited without breaking
+	// decryption. PutSecret and RotateSecret write it; see
+	// Client.ResealSecret for existing records.
+	AADSchemeV2 = "aad_v2"
+)
+
+// MakeRecordAADAndEncCtx returns the AAD and KMS EncryptionContext for rec
+// under rec.AADScheme. AADSchemeV2 needs a canonical key (see ParseKey).
+// Unknown schemes fail with ErrInvalidCiphertext.
+func MakeRecordAADAndEncCtx(rec *SecretRecord) ([]byte, map[string]string, error) {
+	const op = "MakeRecordAADAndEncCtx"
+	switch rec.AADScheme {
+	case "", AADSchemeV1:
+		aad, encCtx := MakeAADAndEncCtx(rec.TenantID, rec.Key)
+		return aad, encCtx, nil
+	case AADSchemeV2:
+		k, err := parseKey(rec.Key, true)
+		if err != nil {
+			return nil, nil, withKey(err, op, rec.Key, rec.Store)
+		}
+		encCtx := map[string]string{
+			"aad_scheme":  AADSchemeV2,
+			"tenant_id":   rec.TenantID.String(),
+			"key":         rec.Key,
+			"secret_id":   rec.ID.String(),
+			"environment": string(k.Environment),
+			"store":       string(rec.Store),
+			"version":     rec.Version,
+		}
+		// AAD: labelled fields in a fixed order; order matters
+		aad := []byte(AADSchemeV2 +
+			"|tenant:" + encCtx["tenant_id"] +
+			"|key:" + encCtx["key"] +
+			"|secret_id:" + encCtx["secret_id"] +
+			"|env:" + encCtx["environment"] +
+			"|store:" + encCtx["store"] +
+			"|version:" + encCtx["version"])
+		return aad, encCtx, nil
+	}
+	return nil, nil, &Error{Op: op, Key: rec.Key, Store: rec.Store,
+		Err: fmt.Errorf("%w: unknown aad scheme %q", ErrInvalidCiphertext, rec.AADScheme)}
+}
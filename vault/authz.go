@@ -0,0 +1,128 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Permissions looked up in SecretRecord.ACL.
+const (
+	PermissionRead = "read"
+)
+
+// Principal is the caller identity that travels in the context and is
+// checked against SecretRecord.ACL.
+type Principal struct {
+	ID       string
+	Roles    []string
+	TenantID uuid.UUID // uuid.Nil when the caller is not tenant-scoped
+}
+
+type principalCtxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// PrincipalFromContext returns the Principal stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(Principal)
+	return p, ok
+}
+
+// Authorizer decides whether the caller in ctx holds perm on rec. It runs
+// before any KMS call and on every plaintext cache hit. A denial should be
+// an *AccessDeniedError.
+type Authorizer interface {
+	Authorize(ctx context.Context, perm string, rec *SecretRecord) error
+}
+
+// AccessDeniedError names the permission the caller was missing. It matches
+// ErrAccessDenied with errors.Is.
+type AccessDeniedError struct {
+	Key        string
+	Permission string
+	Principal  string // empty when the context carried no Principal
+	Reason     string
+}
+
+func (e *AccessDeniedError) Error() string {
+	who := e.Principal
+	if who == "" {
+		who = "anonymous"
+	}
+	msg := fmt.Sprintf("access denied: %s lacks %q on %q", who, e.Permission, e.Key)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *AccessDeniedError) Is(target error) bool { return target == ErrAccessDenied }
+
+// ACLAuthorizer evaluates SecretRecord.ACL, a map from permission to entries
+// such as "role:svc-billing". Supported entries:
+//
+//	"*"               anyone, including callers without a Principal
+//	"principal:<id>"  Principal.ID
+//	"role:<name>"     any of Principal.Roles
+//	"tenant:<uuid>"   Principal.TenantID
+//
+// Entries with an empty id, name or uuid match nobody. A record with an empty
+// ACL is denied to everyone unless AllowEmptyACL is set. A tenant-scoped
+// Principal can never access another tenant's record, whatever the ACL says.
+type ACLAuthorizer struct {
+	// AllowEmptyACL makes records without any ACL entries accessible to
+	// every caller, as they were before ACLs existed. Use it while such
+	// records are being given ACLs.
+	AllowEmptyACL bool
+}
+
+func (a ACLAuthorizer) Authorize(ctx context.Context, perm string, rec *SecretRecord) error {
+	p, hasP := PrincipalFromContext(ctx)
+	deny := func(reason string) error {
+		return &AccessDeniedError{Key: rec.Key, Permission: perm, Principal: p.ID, Reason: reason}
+	}
+	if hasP && p.TenantID != uuid.Nil && p.TenantID != rec.TenantID {
+		return deny("tenant mismatch")
+	}
+	if len(rec.ACL.Data) == 0 {
+		if a.AllowEmptyACL {
+			return nil
+		}
+		return deny("record has no ACL")
+	}
+	for _, entry := range rec.ACL.Data[perm] {
+		if entry == "*" {
+			return nil
+		}
+		kind, val, _ := strings.Cut(entry, ":")
+		if !hasP || val == "" {
+			continue
+		}
+		switch kind {
+		case "principal":
+			if val == p.ID {
+				return nil
+			}
+		case "role":
+			for _, r := range p.Roles {
+				if r == val {
+					return nil
+				}
+			}
+		case "tenant":
+			if p.TenantID != uuid.Nil && val == p.TenantID.String() {
+				return nil
+			}
+		}
+	}
+	if !hasP {
+		return deny("no principal in context")
+	}
+	return deny("")
+}
@@ -0,0 +1,138 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grasp-labs/ds-go-commonmodels/v2/commonmodels/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// openACL lets the clients of tests that are not about authorization read
+// records written without an ACL.
+var openACL = vault.WithAuthorizer(vault.ACLAuthorizer{AllowEmptyACL: true})
+
+func TestClient_GetSecret_EnforcesACL(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	kmsFake := &fakes.KMS{}
+	client := vault.NewClient(vault.NewInMemoryRepo(),
+		vault.NewKMSProvider(kmsFake, 16, time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute),
+		time.Minute)
+
+	_, err := client.PutSecret(ctx, key, []byte("billing-key"), vault.PutOptions{
+		TenantID: tenantID,
+		KEKKeyID: "alias/ds-vault",
+		ACL:      map[string][]string{"read": {"role:svc-billing", "principal:ops-bot"}},
+	})
+	require.NoError(t, err)
+
+	// No principal in context.
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrAccessDenied)
+	require.Equal(t, 0, kmsFake.Calls)
+
+	// Wrong role.
+	_, err = client.GetSecret(vault.WithPrincipal(ctx, vault.Principal{ID: "svc-a", Roles: []string{"svc-search"}}), key)
+	var denied *vault.AccessDeniedError
+	require.True(t, errors.As(err, &denied))
+	require.Equal(t, vault.PermissionRead, denied.Permission)
+	require.Equal(t, "svc-a", denied.Principal)
+	require.Equal(t, 0, kmsFake.Calls)
+
+	// Matching role.
+	billing := vault.WithPrincipal(ctx, vault.Principal{ID: "svc-b", Roles: []string{"svc-billing"}, TenantID: tenantID})
+	pt, err := client.GetSecret(billing, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("billing-key"), pt)
+
+	// Matching principal id, served from cache.
+	pt, err = client.GetSecret(vault.WithPrincipal(ctx, vault.Principal{ID: "ops-bot"}), key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("billing-key"), pt)
+	require.Equal(t, 1, kmsFake.Calls)
+
+	// Cache hits are authorized too, including the tenant check.
+	_, err = client.GetSecret(vault.WithPrincipal(ctx, vault.Principal{ID: "svc-b", Roles: []string{"svc-billing"}, TenantID: uuid.New()}), key)
+	require.ErrorIs(t, err, vault.ErrAccessDenied)
+}
+
+func TestClient_GetSecret_WithoutACL(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	repo := vault.NewInMemoryRepo()
+	kmsProv := vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute)
+	client := vault.NewClient(repo,
+		kmsProv,
+		vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute),
+		time.Minute)
+	_, err := client.PutSecret(ctx, key, []byte("open"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+
+	// Denied by default, to callers with and without a Principal.
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrAccessDenied)
+	_, err = client.GetSecret(vault.WithPrincipal(ctx, vault.Principal{ID: "svc-a", TenantID: tenantID}), key)
+	require.ErrorIs(t, err, vault.ErrAccessDenied)
+
+	open := vault.NewClient(repo, kmsProv, nil, time.Minute, openACL)
+	pt, err := open.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("open"), pt)
+}
+
+func TestACLAuthorizer_EmptyEntriesMatchNobody(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	rec := &vault.SecretRecord{Key: "k", ACL: types.JSONB[map[string][]string]{Data: map[string][]string{
+		vault.PermissionRead: {"principal:", "role:", "tenant:"},
+	}}}
+	for _, allowEmpty := range []bool{false, true} {
+		authz := vault.ACLAuthorizer{AllowEmptyACL: allowEmpty}
+		err := authz.Authorize(vault.WithPrincipal(ctx, vault.Principal{Roles: []string{""}}), vault.PermissionRead, rec)
+		require.ErrorIs(t, err, vault.ErrAccessDenied)
+	}
+}
+
+func TestClient_GetSecret_AuthorizesBeforeStatus(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute)
+
+	_, err := client.PutSecret(ctx, key, []byte("billing-key"), vault.PutOptions{
+		TenantID: tenantID,
+		KEKKeyID: "alias/ds-vault",
+		ACL:      map[string][]string{"read": {"role:svc-billing"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.SoftDeleteSecret(ctx, key))
+
+	// Callers without read permission cannot tell a deleted secret from a
+	// denied one, on current and historic reads alike.
+	billing := vault.WithPrincipal(ctx, vault.Principal{ID: "svc-b", Roles: []string{"svc-billing"}, TenantID: tenantID})
+	for _, opts := range []vault.ReadOptions{{}, {Version: "v1"}} {
+		_, err = client.GetSecretWithOptions(ctx, key, opts)
+		require.ErrorIs(t, err, vault.ErrAccessDenied)
+		require.NotErrorIs(t, err, vault.ErrSecretDeleted)
+
+		_, err = client.GetSecretWithOptions(billing, key, opts)
+		require.ErrorIs(t, err, vault.ErrSecretDeleted)
+	}
+}
@@ -1,83 +1,232 @@
 package vault
 
 import (
+	"container/list"
 	"sync"
 	"time"
 )
 
-// ttlItem holds a cached value and its absolute expiration (Unix seconds).
-// Internal helper; not exported.
+// ttlItem holds a cached value, its absolute expiration (Unix nanoseconds)
+// and its accounted size in bytes. Internal helper; not exported.
 type ttlItem[T any] struct {
-	v   T
-	exp int64
+	k     string
+	v     T
+	exp   int64
+	size  int64
+	timer *time.Timer // expiry timer for values holding secrets (see cacheEvictor)
 }
 
-// TTLCache is a tiny, goroutine-safe in-memory key/value cache with a fixed
-// capacity and a time-to-live (TTL) applied to each entry.
+// CacheOptions bounds a TTLCache built by NewCache.
+type CacheOptions struct {
+	// MaxEntries caps the number of entries. <= 0 means no entry bound.
+	MaxEntries int
+	// MaxBytes caps the accounted size of all entries (see TTLCache).
+	// <= 0 means no byte bound.
+	MaxBytes int64
+	// TTL is applied to each entry on Set. A non-positive TTL effectively
+	// disables caching (items expire immediately).
+	TTL time.Duration
+}
+
+// TTLCache is a goroutine-safe in-memory key/value cache with a least
+// recently used (LRU) eviction policy, optional bounds on entry count and
+// total bytes, and a time-to-live (TTL) applied to each entry.
 //
 //   - Concurrency: protected by a single mutex; safe for concurrent use.
 //   - Expiration: entries expire lazily on Get when their exp < now.
-//   - Eviction: when capacity is reached, the oldest *inserted key* is evicted
-//     (simple FIFO). Each Set appends the key to a FIFO queue; duplicate keys
-//     therefore occupy multiple positions and may be evicted earlier than an
-//     LRU would.
-//   - Time resolution: expiration is tracked at 1-second granularity.
+//   - Eviction: Get and Set mark an entry as most recently used; when a bound
+//     is exceeded, least recently used entries are evicted until it holds
+//     again. All operations are O(1) (amortized for eviction).
+//   - Re-Set: setting an existing key replaces its value, size and expiry;
+//     it never leaves stale bookkeeping behind.
+//   - Size: an entry accounts for len(key) plus len(v) for []byte and string
+//     values, and the encoded fields for *SecretRecord and the Client's
+//     plaintext entries; other types count their key only. A single entry
+//     larger than MaxBytes is not cached.
+//   - Secrets: values holding secret material (*SecureBytes and the Client's
+//     plaintext entries) are destroyed as soon as they leave the cache,
+//     whether by eviction, Delete, replacement, Clear or expiry. They are
+//     removed by a timer when they expire rather than lazily, so expired
+//     secrets do not linger until the next Get.
 //   - Zero value: the zero value of TTLCache is not ready for use; call
-//     NewTTLCache to initialize internal fields.
+//     NewTTLCache or NewCache to initialize internal fields. A nil
+//     *TTLCache is a disabled cache: Get always misses and Set discards.
 type TTLCache[T any] struct {
-	mu   sync.Mutex
-	ttl  time.Duration
-	size int
-	data map[string]ttlItem[T]
-	keys []string // simple FIFO eviction queue (by insertion occurrences)
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	ll         *list.List // front = most recently used; values are *ttlItem[T]
+	data       map[string]*list.Element
 }
 
-// NewTTLCache constructs a TTLCache with the given maximum size and TTL per
-// entry. A non-positive ttl effectively disables caching (items expire
-// immediately).
+// NewTTLCache constructs a TTLCache holding at most size entries, with the
+// given TTL per entry. A size below 1 holds a single entry, as it always
+// has; use NewCache for a cache without an entry bound. A non-positive ttl
+// effectively disables caching (items expire immediately).
 func NewTTLCache[T any](size int, ttl time.Duration) *TTLCache[T] {
-	return &TTLCache[T]{ttl: ttl, size: size, data: make(map[string]ttlItem[T])}
+	return NewCache[T](CacheOptions{MaxEntries: max(size, 1), TTL: ttl})
+}
+
+// NewCache constructs a TTLCache bounded by opts. Leaving both MaxEntries
+// and MaxBytes unset asks for a cache without any bound.
+func NewCache[T any](opts CacheOptions) *TTLCache[T] {
+	return &TTLCache[T]{
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		maxBytes:   opts.MaxBytes,
+		ll:         list.New(),
+		data:       make(map[string]*list.Element),
+	}
 }
 
 // Get returns the cached value for key k if present and not expired.
-// On hit, it returns (value, true). If the key is absent or the entry has
-// expired, it returns the zero value of T and false. Expired entries are
-// removed lazily during this call.
+// On hit, it returns (value, true) and marks k as most recently used. If the
+// key is absent or the entry has expired, it returns the zero value of T and
+// false. Expired entries are removed lazily during this call.
 func (c *TTLCache[T]) Get(k string) (T, bool) {
 	var zero T
-	now := time.Now().Unix()
+	if c == nil {
+		return zero, false
+	}
+	now := time.Now().UnixNano()
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	it, ok := c.data[k]
-	if !ok || it.exp < now {
-		if ok {
-			delete(c.data, k)
-		}
+	el, ok := c.data[k]
+	if !ok {
+		return zero, false
+	}
+	it := el.Value.(*ttlItem[T])
+	if it.exp < now {
+		c.remove(el)
 		return zero, false
 	}
+	c.ll.MoveToFront(el)
 	return it.v, true
 }
 
 // Set inserts or replaces the value for key k with an expiration time of
-// now + cache TTL. If the cache is at capacity, it evicts the oldest key
-// according to the internal FIFO queue and then inserts the new item.
-//
-// Note: each call appends k to the FIFO queue. If the same key is Set
-// repeatedly, older queue entries remain; when they reach the front, the
-// eviction step will delete the current mapping for k. This behavior is
-// intentional for simplicity (FIFO by insertion), and differs from LRU.
+// now + cache TTL and marks it as most recently used, then evicts least
+// recently used entries until the cache is within its bounds.
 func (c *TTLCache[T]) Set(k string, v T) {
-	now := time.Now().Add(c.ttl).Unix()
+	if c == nil {
+		if ev, ok := any(v).(cacheEvictor); ok {
+			ev.evict()
+		}
+		return
+	}
+	exp := time.Now().Add(c.ttl).UnixNano()
+	size := int64(len(k)) + sizeOf(v)
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if len(c.data) >= c.size {
-		// evict oldest key by insertion order
-		if len(c.keys) > 0 {
-			old := c.keys[0]
-			c.keys = c.keys[1:]
-			delete(c.data, old)
+	if el, ok := c.data[k]; ok {
+		c.remove(el)
+	}
+	if c.maxBytes > 0 && size > c.maxBytes {
+		if ev, ok := any(v).(cacheEvictor); ok {
+			ev.evict()
 		}
+		return
+	}
+	it := &ttlItem[T]{k: k, v: v, exp: exp, size: size}
+	el := c.ll.PushFront(it)
+	c.data[k] = el
+	c.bytes += size
+	if _, ok := any(v).(cacheEvictor); ok {
+		it.timer = time.AfterFunc(c.ttl, func() { c.expire(el) })
+	}
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Delete removes key k from the cache if present.
+func (c *TTLCache[T]) Delete(k string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.data[k]; ok {
+		c.remove(el)
+	}
+}
+
+// Clear removes every entry.
+func (c *TTLCache[T]) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Len reports the number of entries, including expired ones not yet removed.
+func (c *TTLCache[T]) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Bytes reports the accounted size of all entries.
+func (c *TTLCache[T]) Bytes() int64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// expire removes el when its timer fires, unless it already left the cache.
+func (c *TTLCache[T]) expire(el *list.Element) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it := el.Value.(*ttlItem[T])
+	if cur, ok := c.data[it.k]; ok && cur == el {
+		c.remove(el)
+	}
+}
+
+// remove unlinks el and destroys secret values; c.mu must be held.
+func (c *TTLCache[T]) remove(el *list.Element) {
+	it := c.ll.Remove(el).(*ttlItem[T])
+	delete(c.data, it.k)
+	c.bytes -= it.size
+	if it.timer != nil {
+		it.timer.Stop()
+	}
+	if ev, ok := any(it.v).(cacheEvictor); ok {
+		ev.evict()
+	}
+}
+
+// cacheEvictor is implemented by internal values holding secret material;
+// evict wipes them when they leave the cache.
+type cacheEvictor interface {
+	evict()
+}
+
+// cacheSizer is implemented by internal values that know their own size.
+type cacheSizer interface {
+	cacheSize() int64
+}
+
+func sizeOf(v any) int64 {
+	switch v := v.(type) {
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	case cacheSizer:
+		return v.cacheSize()
 	}
-	c.data[k] = ttlItem[T]{v: v, exp: now}
-	c.keys = append(c.keys, k)
+	return 0
 }
@@ -34,3 +34,80 @@ func TestCache_TTL(t *testing.T) {
 	assert.Nil(t, got)
 
 }
+
+func TestCache_LRUEviction(t *testing.T) {
+	c := vault.NewTTLCache[string](2, time.Minute)
+	c.Set("a", "1")
+	c.Set("b", "2")
+	_, ok := c.Get("a") // a is now most recently used
+	assert.True(t, ok)
+	c.Set("c", "3")
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_NonPositiveSizeStaysBounded(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		c := vault.NewTTLCache[string](size, time.Minute)
+		c.Set("a", "1")
+		c.Set("b", "2")
+		assert.Equal(t, 1, c.Len(), "size %d", size)
+		_, ok := c.Get("b")
+		assert.True(t, ok)
+	}
+
+	// Unbounded caches have to be asked for.
+	c := vault.NewCache[string](vault.CacheOptions{TTL: time.Minute})
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, k)
+	}
+	assert.Equal(t, 3, c.Len())
+}
+
+func TestCache_ReSetKeepsLiveEntry(t *testing.T) {
+	c := vault.NewTTLCache[string](2, time.Minute)
+	for range 10 {
+		c.Set("hot", "v")
+	}
+	c.Set("x", "1")
+	c.Set("hot", "v2")
+	c.Set("y", "2")
+
+	v, ok := c.Get("hot")
+	assert.True(t, ok, "re-Set key must not be evicted by its own stale slots")
+	assert.Equal(t, "v2", v)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_ByteBound(t *testing.T) {
+	c := vault.NewCache[[]byte](vault.CacheOptions{MaxBytes: 64, TTL: time.Minute})
+	c.Set("k1", make([]byte, 30)) // 32 bytes with key
+	c.Set("k2", make([]byte, 30))
+	assert.Equal(t, int64(64), c.Bytes())
+
+	c.Set("k3", make([]byte, 10))
+	_, ok := c.Get("k1")
+	assert.False(t, ok)
+	assert.Equal(t, int64(44), c.Bytes())
+
+	// Replacing a value re-accounts its size.
+	c.Set("k2", make([]byte, 2))
+	assert.Equal(t, int64(16), c.Bytes())
+
+	// An entry larger than the whole budget is not cached, and drops the
+	// previous value for its key.
+	c.Set("k3", make([]byte, 100))
+	_, ok = c.Get("k3")
+	assert.False(t, ok)
+	assert.Equal(t, int64(4), c.Bytes())
+
+	c.Delete("k2")
+	assert.Equal(t, int64(0), c.Bytes())
+	assert.Equal(t, 0, c.Len())
+}
@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// DEK algorithms recorded in SecretRecord.DEKAlg, besides DEKAlgAES256GCM.
+const (
+	// DEKAlgXChaCha20Poly1305 uses 192-bit random nonces, so nonce
+	// collisions are not a concern at any realistic write volume.
+	DEKAlgXChaCha20Poly1305 = "XCHACHA20-POLY1305"
+	// DEKAlgAES256GCMSIV is AES-256-GCM-SIV (RFC 8452): a repeated nonce
+	// only reveals that the same plaintext was sealed twice.
+	DEKAlgAES256GCMSIV = "AES256-GCM-SIV"
+)
+
+// Cipher is the AEAD a DEK seals secrets with. Client picks it from the
+// registry by SecretRecord.DEKAlg: DEKAlgAES256GCM, DEKAlgXChaCha20Poly1305
+// and DEKAlgAES256GCMSIV are built in, more can be added with WithCipher.
+// The nonce is stored in SecretRecord.IV and the last Overhead() bytes of
+// the sealed output in SecretRecord.Tag.
+type Cipher interface {
+	// KeySize is the DEK length in bytes. DEKs of any other length are
+	// rejected before NewAEAD is called.
+	KeySize() int
+	NewAEAD(key []byte) (cipher.AEAD, error)
+}
+
+// WithCipher registers c for records whose DEKAlg is alg, replacing any
+// built-in cipher for it. KeyProvider.GenerateDEK returns 32-byte DEKs, so
+// writes need a KeySize of 32.
+func WithCipher(alg string, c Cipher) ClientOption {
+	return func(cl *Client) { cl.ciphers[alg] = c }
+}
+
+type aeadCipher struct {
+	keySize int
+	new     func(key []byte) (cipher.AEAD, error)
+}
+
+func (c aeadCipher) KeySize() int                            { return c.keySize }
+func (c aeadCipher) NewAEAD(key []byte) (cipher.AEAD, error) { return c.new(key) }
+
+// Built-in ciphers, registered under their DEKAlg. Register them under
+// other names with WithCipher, e.g. to read another writer's spelling of an
+// algorithm.
+var (
+	AES256GCM Cipher = aeadCipher{32, func(key []byte) (cipher.AEAD, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}}
+	XChaCha20Poly1305 Cipher = aeadCipher{chacha20poly1305.KeySize, chacha20poly1305.NewX}
+	AES256GCMSIV      Cipher = aeadCipher{32, newGCMSIV}
+)
+
+// defaultCiphers returns the built-in registry. Records written before
+// DEKAlg was recorded have it empty; "AES-256-GCM" is a legacy spelling.
+func defaultCiphers() map[string]Cipher {
+	return map[string]Cipher{
+		"":                      AES256GCM,
+		"AES-256-GCM":           AES256GCM,
+		DEKAlgAES256GCM:         AES256GCM,
+		DEKAlgXChaCha20Poly1305: XChaCha20Poly1305,
+		DEKAlgAES256GCMSIV:      AES256GCMSIV,
+		DEKAlgAES256GCMCommit:   AES256GCMCommit,
+	}
+}
+
+// cipher returns the Cipher registered for alg, or ErrUnknownAlgorithm.
+func (c *Client) cipher(op, alg string) (Cipher, error) {
+	ci, ok := c.ciphers[alg]
+	if !ok {
+		return nil, &Error{Op: op, Err: fmt.Errorf("%w: dek algorithm %q", ErrUnknownAlgorithm, alg)}
+	}
+	return ci, nil
+}
+
+// newAEAD keys ci with dek. DEKs of the wrong size fail with sentinel.
+func newAEAD(op, alg string, ci Cipher, dek []byte, sentinel error) (cipher.AEAD, error) {
+	if len(dek) != ci.KeySize() {
+		return nil, errorf(op, "", sentinel, "%s needs a %d-byte dek, got %d", alg, ci.KeySize(), len(dek))
+	}
+	a, err := ci.NewAEAD(dek)
+	if err != nil {
+		return nil, errorf(op, "", sentinel, "dek: %v", err)
+	}
+	return a, nil
+}
+
+// checkKEKAlg rejects records whose DEK was wrapped by a different kind of
+// key provider than the Client's, before any unwrap call is made. Records
+// without KEKAlg, and unwrappers that do not report one, pass.
+func (c *Client) checkKEKAlg(op string, rec *SecretRecord) error {
+	kp, ok := c.keys.(interface{ KEKAlg() string })
+	if !ok || rec.KEKAlg == "" || rec.KEKAlg == kp.KEKAlg() {
+		return nil
+	}
+	return &Error{Op: op, Err: fmt.Errorf("%w: kek algorithm %q, key unwrapper %T uses %q",
+		ErrUnknownAlgorithm, rec.KEKAlg, c.keys, kp.KEKAlg())}
+}
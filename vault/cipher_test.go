@@ -0,0 +1,192 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// RFC 8452, Appendix C.2 (AEAD_AES_256_GCM_SIV).
+func TestCipher_AES256GCMSIV_RFC8452(t *testing.T) {
+	t.Parallel()
+	aead, err := vault.AES256GCMSIV.NewAEAD(unhex(t, "0100000000000000000000000000000000000000000000000000000000000000"))
+	require.NoError(t, err)
+	nonce := unhex(t, "030000000000000000000000")
+
+	for _, tc := range []struct{ aad, plaintext, want string }{
+		{"", "", "07f5f4169bbf55a8400cd47ea6fd400f"},
+		{"", "0100000000000000", "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28"},
+		{"", "010000000000000000000000", "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e"},
+		{"", "01000000000000000000000000000000", "85a01b63025ba19b7fd3ddfc033b3e76c9eac6fa700942702e90862383c6c366"},
+		{"", "0100000000000000000000000000000002000000000000000000000000000000",
+			"4a6a9db4c8c6549201b9edb53006cba821ec9cf850948a7c86c68ac7539d027fe819e63abcd020b006a976397632eb5d"},
+		{"", "010000000000000000000000000000000200000000000000000000000000000003000000000000000000000000000000",
+			"c00d121893a9fa603f48ccc1ca3c57ce7499245ea0046db16c53c7c66fe717e39cf6c748837b61f6ee3adcee17534ed5790bc96880a99ba804bd12c0e6a22cc4"},
+		{"", "01000000000000000000000000000000020000000000000000000000000000000300000000000000000000000000000004000000000000000000000000000000",
+			"c2d5160a1f8683834910acdafc41fbb1632d4a353e8b905ec9a5499ac34f96c7e1049eb080883891a4db8caaa1f99dd004d80487540735234e3744512c6f90ce112864c269fc0d9d88c61fa47e39aa08"},
+		{"01", "0200000000000000", "1de22967237a813291213f267e3b452f02d01ae33e4ec854"},
+		{"01", "020000000000000000000000", "163d6f9cc1b346cd453a2e4cc1a4a19ae800941ccdc57cc8413c277f"},
+		{"01", "02000000000000000000000000000000", "c91545823cc24f17dbb0e9e807d5ec17b292d28ff61189e8e49f3875ef91aff7"},
+		{"01", "0200000000000000000000000000000003000000000000000000000000000000",
+			"07dad364bfc2b9da89116d7bef6daaaf6f255510aa654f920ac81b94e8bad365aea1bad12702e1965604374aab96dbbc"},
+		{"01", "020000000000000000000000000000000300000000000000000000000000000004000000000000000000000000000000",
+			"c67a1f0f567a5198aa1fcc8e3f21314336f7f51ca8b1af61feac35a86416fa47fbca3b5f749cdf564527f2314f42fe2503332742b228c647173616cfd44c54eb"},
+		{"01", "02000000000000000000000000000000030000000000000000000000000000000400000000000000000000000000000005000000000000000000000000000000",
+			"67fd45e126bfb9a79930c43aad2d36967d3f0e4d217c1e551f59727870beefc98cb933a8fce9de887b1e40799988db1fc3f91880ed405b2dd298318858467c895bde0285037c5de81e5b570a049b62a0"},
+		{"010000000000000000000000", "02000000", "22b3f4cd1835e517741dfddccfa07fa4661b74cf"},
+		{"010000000000000000000000000000000200", "0300000000000000000000000000000004000000",
+			"43dd0163cdb48f9fe3212bf61b201976067f342bb879ad976d8242acc188ab59cabfe307"},
+		{"0100000000000000000000000000000002000000", "030000000000000000000000000000000400",
+			"462401724b5ce6588d5a54aae5375513a075cfcdf5042112aa29685c912fc2056543"},
+	} {
+		aad := unhex(t, tc.aad)
+		sealed := aead.Seal(nil, nonce, unhex(t, tc.plaintext), aad)
+		require.Equal(t, tc.want, hex.EncodeToString(sealed), tc.plaintext)
+		pt, err := aead.Open(nil, nonce, sealed, aad)
+		require.NoError(t, err)
+		require.Equal(t, tc.plaintext, hex.EncodeToString(pt))
+
+		// A flipped tag bit or different AAD is rejected.
+		tampered := append([]byte(nil), sealed...)
+		tampered[len(tampered)-1] ^= 0x80
+		_, err = aead.Open(nil, nonce, tampered, aad)
+		require.Error(t, err, tc.plaintext)
+		_, err = aead.Open(nil, nonce, sealed, append(aad, 0))
+		require.Error(t, err, tc.plaintext)
+		if len(aad) > 0 {
+			_, err = aead.Open(nil, nonce, sealed, nil)
+			require.Error(t, err, tc.plaintext)
+		}
+	}
+
+	// Longer messages with AAD round-trip; any flipped bit fails.
+	msg := make([]byte, 1000)
+	_, _ = rand.Read(msg)
+	sealed := aead.Seal(nil, nonce, msg, []byte("aad"))
+	pt, err := aead.Open(nil, nonce, sealed, []byte("aad"))
+	require.NoError(t, err)
+	require.Equal(t, msg, pt)
+	_, err = aead.Open(nil, nonce, sealed, []byte("aaD"))
+	require.Error(t, err)
+	sealed[500] ^= 1
+	_, err = aead.Open(nil, nonce, sealed, []byte("aad"))
+	require.Error(t, err)
+
+	_, err = vault.AES256GCMSIV.NewAEAD(make([]byte, 16))
+	require.Error(t, err)
+}
+
+func TestClient_DEKAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	for alg, ivSize := range map[string]int{
+		vault.DEKAlgAES256GCM:         12,
+		vault.DEKAlgXChaCha20Poly1305: 24,
+		vault.DEKAlgAES256GCMSIV:      12,
+	} {
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			tenantID := uuid.New()
+			key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+			client := vault.NewClient(vault.NewInMemoryRepo(), vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute, openACL)
+
+			rec, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault", DEKAlg: alg})
+			require.NoError(t, err)
+			require.Equal(t, alg, rec.DEKAlg)
+			iv, err := base64.StdEncoding.DecodeString(rec.IV)
+			require.NoError(t, err)
+			require.Len(t, iv, ivSize)
+
+			pt, err := client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, "s3cr3t", string(pt))
+
+			rec, err = client.RotateSecret(ctx, key, []byte("n3w"))
+			require.NoError(t, err)
+			require.Equal(t, alg, rec.DEKAlg, "rotation keeps the algorithm")
+			pt, err = client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, "n3w", string(pt))
+		})
+	}
+}
+
+func TestClient_UnknownAlgorithm(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	kmsFake := &fakes.KMS{}
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), nil, time.Minute, openACL)
+
+	_, err := client.PutSecret(ctx, key, []byte("x"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault", DEKAlg: "ROT13"})
+	require.ErrorIs(t, err, vault.ErrUnknownAlgorithm)
+	require.Zero(t, kmsFake.GenerateCalls)
+
+	rec, err := client.PutSecret(ctx, key, []byte("x"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+
+	for _, edit := range []func(*vault.SecretRecord){
+		func(r *vault.SecretRecord) { r.DEKAlg = "ROT13" },
+		func(r *vault.SecretRecord) { r.KEKAlg = vault.KEKAlgAESKW },
+	} {
+		bad := *rec
+		edit(&bad)
+		repo.Put(&bad)
+		client.Invalidate(key)
+		_, err = client.GetSecret(ctx, key)
+		require.ErrorIs(t, err, vault.ErrUnknownAlgorithm)
+		require.Zero(t, kmsFake.Calls, "rejected before unwrapping")
+	}
+
+	// A registered alias reads the record.
+	aliased := *rec
+	aliased.DEKAlg = "AES_256_GCM"
+	repo.Put(&aliased)
+	withAlias := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), nil, time.Minute,
+		vault.WithCipher("AES_256_GCM", vault.AES256GCM), openACL)
+	pt, err := withAlias.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "x", string(pt))
+}
+
+func TestClient_DEKSizeValidated(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	aad, _ := vault.MakeAADAndEncCtx(tenantID, key)
+	dek := make([]byte, 16) // AES-128: aes.NewCipher accepts it, AES256-GCM must not
+	ivB64, valueB64, tagB64, err := fakes.EncryptWithDEK(append(dek, dek...), []byte("x"), aad)
+	require.NoError(t, err)
+
+	repo := vault.NewInMemoryRepo()
+	require.NoError(t, repo.CreateSecret(ctx, &vault.SecretRecord{
+		ID: keySecretID(t, key), TenantID: tenantID, Key: key, Store: vault.StoreDSVault, Status: vault.StatusActive,
+		Value: valueB64, IV: ivB64, Tag: tagB64, WrappedDEK: "V1JBUFBFRA==", DEKAlg: vault.DEKAlgAES256GCM,
+	}))
+	client := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{Plaintext: dek}, 16, time.Minute), nil, time.Minute, openACL)
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrInvalidCiphertext)
+}
@@ -2,98 +2,424 @@ package vault
 
 import (
 	"context"
-	"fmt"
+	"encoding/base64"
+	"errors"
+	"sync"
 	"time"
 )
 
 // Client pulls secret metadata from the repository, retrieves ciphertext
-// (from SSM if configured), unwraps the DEK via KMS, and decrypts the secret.
+// from the CiphertextStore registered for the record's Store, unwraps the
+// DEK via its KeyUnwrapper (AWS KMS or a LocalKeyring), and decrypts the
+// secret.
 // Decrypted plaintexts are memoized in a small in-memory TTL cache to avoid
 // repeated KMS/SSM calls.
 //
 // Flow on GetSecret:
-//  1. Check plaintext cache; if present and valid, return immediately.
-//  2. Load SecretRecord from the SecretRepository by composite key.
-//  3. Derive AAD and KMS EncryptionContext using MakeAADAndEncCtx(rec.TenantID, rec.Key).
-//  4. Unwrap the DEK with KMS (Decrypt using rec.WrappedDEK and rec.KEKKeyID).
-//  5. If Store==StoreAWSSSM, fetch Base64(ciphertext) from SSM by rec.Key;
-//     otherwise use rec.Value from the DB. IV and Tag are stored in the record.
-//  6. AES-GCM decrypt using (DEK, IV, Tag, AAD), cache plaintext under key, return.
+//  1. Check plaintext cache; if present and valid, authorize and return.
+//  2. Load SecretRecord from the repository by composite key and
+//     reject it unless the key names its id, tenant and store (see ParseKey),
+//     Status==StatusActive (see ReadOptions) and the Authorizer grants
+//     PermissionRead on it.
+//  3. Derive AAD and KMS EncryptionContext using MakeRecordAADAndEncCtx(rec),
+//     which follows rec.AADScheme.
+//  4. Unwrap the DEK with the KeyUnwrapper (KMS Decrypt using rec.WrappedDEK and rec.KEKKeyID).
+//  5. Fetch Base64(ciphertext) from the CiphertextStore for rec.Store (SSM by
+//     rec.Key for StoreAWSSSM, rec.Value for StoreDSVault). An unregistered
+//     store fails with ErrUnknownStore. IV and Tag are stored in the record.
+//  6. Decrypt using (DEK, IV, Tag, AAD) with the Cipher registered for
+//     rec.DEKAlg (AES-256-GCM by default; see WithCipher), cache plaintext
+//     under key, return.
 //
 // Concurrency: Client is safe for concurrent use as long as the injected
 // providers and repository are safe; the internal plaintext cache is
-// guarded and TTL-based.
-// Errors: Any underlying repository / KMS / SSM / crypto error bubbles up.
+// guarded, LRU-bounded (see WithPlaintextCacheLimits) and TTL-based.
+// Concurrent misses for the same key share one repository query and one
+// unwrap/fetch/decrypt; status and authorization
+// are still checked per caller, and a caller whose ctx is cancelled stops
+// waiting without cancelling the shared work for the others.
+// Errors: every error is a *Error carrying the key and store; match causes
+// with errors.Is against the sentinels in errors.go (ErrNotFound, ...).
 type Client struct {
-	repo SecretRepository
-	kms  *KMSProvider
-	ssm  *SSMProvider
+	repo    SecretReader
+	keys    KeyUnwrapper
+	stores  map[Store]CiphertextStore
+	ciphers map[string]Cipher
+	authz   Authorizer
 
-	plaintextCache *TTLCache[[]byte]
+	cacheOpts      CacheOptions
+	plaintextCache *TTLCache[*cachedSecret]
+	negative       *negativeCache // nil unless WithNegativeCache
+	lockMemory     bool
+	ttl            time.Duration
+	loads          flightGroup[*SecretRecord]
+	opens          secretFlight
+
+	// background refresh (see WithBackgroundRefresh)
+	refresh    *RefreshOptions
+	refreshing sync.Map // key -> struct{} while a refresh runs
+	bgMu       sync.Mutex
+	bgCtx      context.Context
+	bgCancel   context.CancelFunc
+	bgWG       sync.WaitGroup
+}
+
+// cachedSecret keeps the record next to its plaintext so cache hits can be
+// authorized like misses.
+type cachedSecret struct {
+	rec     *SecretRecord
+	pt      *SecureBytes
+	fetched time.Time
+}
+
+func (s *cachedSecret) cacheSize() int64 {
+	return int64(s.pt.Len()) + s.rec.cacheSize()
+}
+
+func (s *cachedSecret) evict() { s.pt.Destroy() }
+
+// ClientOption customizes a Client built by NewClient.
+type ClientOption func(*Client)
+
+// WithAuthorizer replaces the default ACLAuthorizer. A nil Authorizer
+// disables access checks.
+func WithAuthorizer(a Authorizer) ClientOption {
+	return func(c *Client) { c.authz = a }
+}
+
+// WithPlaintextCacheLimits bounds the plaintext cache to maxEntries entries
+// and maxBytes bytes of plaintext plus record metadata; least recently used
+// entries are evicted first. A value <= 0 disables that bound. The default
+// is 4096 entries with no byte bound.
+func WithPlaintextCacheLimits(maxEntries int, maxBytes int64) ClientOption {
+	return func(c *Client) {
+		c.cacheOpts.MaxEntries = maxEntries
+		c.cacheOpts.MaxBytes = maxBytes
+	}
+}
+
+// WithNegativeCache makes the Client remember keys the repository reported
+// as not found for ttl, so a misconfigured key path costs one repository
+// query per ttl instead of one per call. Remembered keys fail with
+// ErrNotFound; PutSecret, Invalidate and PurgeNegativeCache forget them.
+// ttl <= 0 disables negative caching, which is the default.
+func WithNegativeCache(ttl time.Duration) ClientOption {
+	return func(c *Client) { c.negative = newNegativeCache(ttl) }
+}
+
+// WithLockedMemory keeps cached plaintexts, the plaintext shared by
+// concurrent reads, and SecureBytes returned by GetSecureSecret, in mlock'ed
+// memory so they are never written to swap.
+// It is best effort: on platforms other than Linux, or once RLIMIT_MEMLOCK is
+// exhausted, plaintexts fall back to ordinary memory (see
+// SecureBytes.Locked). Each locked plaintext occupies at least one page.
+func WithLockedMemory() ClientOption {
+	return func(c *Client) { c.lockMemory, c.opens.lock = true, true }
 }
 
 // NewClient builds a Client from the given repository and providers.
 // ptCacheTTL controls how long decrypted plaintexts are retained in the
 // in-memory cache. If ptCacheTTL <= 0, a default of one minute is used.
-// kms and ssm must be non-nil; this function panics if either is nil.
-func NewClient(repo SecretRepository, kms *KMSProvider, ssm *SSMProvider, ptCacheTTL time.Duration) *Client {
-	if kms == nil {
-		panic("kms provider is required")
-	}
-	if ssm == nil {
-		panic("ssm provider is required")
+// keys must be non-nil; this function panics otherwise. keys is usually a
+// *KMSProvider; writes additionally need it to implement KeyProvider.
+// ssm is optional: when nil, StoreAWSSSM records cannot be read unless a
+// store is registered for them with WithCiphertextStore. Reads are
+// authorized with ACLAuthorizer unless WithAuthorizer says otherwise.
+func NewClient(repo SecretReader, keys KeyUnwrapper, ssm *SSMProvider, ptCacheTTL time.Duration, opts ...ClientOption) *Client {
+	if kp, ok := keys.(*KMSProvider); keys == nil || (ok && kp == nil) {
+		panic("key unwrapper is required")
 	}
 	if ptCacheTTL <= 0 {
 		ptCacheTTL = time.Minute
 	}
-	return &Client{
-		repo:           repo,
-		kms:            kms,
-		ssm:            ssm,
-		plaintextCache: NewTTLCache[[]byte](4096, ptCacheTTL),
+	c := &Client{
+		repo:      repo,
+		keys:      keys,
+		stores:    map[Store]CiphertextStore{StoreDSVault: dbStore{}},
+		ciphers:   defaultCiphers(),
+		authz:     ACLAuthorizer{},
+		cacheOpts: CacheOptions{MaxEntries: 4096, TTL: ptCacheTTL},
+		ttl:       ptCacheTTL,
+	}
+	if ssm != nil {
+		c.stores[StoreAWSSSM] = ssm
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.startRefresh()
+	c.plaintextCache = NewCache[*cachedSecret](c.cacheOpts)
+	return c
+}
+
+// ReadOptions relaxes the checks GetSecretWithOptions applies to a record.
+// The zero value is what GetSecret uses.
+type ReadOptions struct {
+	// AllowDraft lets admin tooling read records with Status==StatusDraft.
+	// Draft plaintexts are never cached.
+	AllowDraft bool
+	// Version reads a specific version of the secret: a SecretRecord.Version
+	// or VersionPrevious. Empty (or VersionCurrent) reads the current one.
+	// The repository must implement SecretVersioner, and external stores
+	// VersionedCiphertextStore (SSMProvider does; see
+	// SecretRecord.StoreVersion).
+	Version string
 }
 
 // GetSecret returns the decrypted plaintext for the given composite key.
 // It first checks the in-memory plaintext cache. On miss, it loads the
-// SecretRecord from the repository, unwraps the DEK via KMS using an exact
-// EncryptionContext derived from the record, fetches ciphertext from SSM
-// when Store==StoreAWSSSM (otherwise uses the DB value), decrypts using
-// AES-GCM with AAD, caches the plaintext, and returns it.
+// SecretRecord from the repository, rejects it unless it is active,
+// unwraps the DEK via KMS using an exact EncryptionContext derived from the
+// record, fetches ciphertext from the record's CiphertextStore, decrypts with the Cipher for rec.DEKAlg and AAD, caches the
+// plaintext, and returns it.
+//
+// Records that are not active fail with ErrSecretDeleted,
+// ErrSecretSuspended, ErrSecretRejected, ErrSecretDraft, ErrSecretClosed or
+// ErrSecretInactive, and callers the Authorizer rejects get an
+// *AccessDeniedError (ErrAccessDenied), both before any KMS call is made.
+// The caller identity is taken from ctx (see WithPrincipal).
 func (c *Client) GetSecret(ctx context.Context, key string) ([]byte, error) {
-	if pt, ok := c.plaintextCache.Get(key); ok {
-		return pt, nil
+	return c.GetSecretWithOptions(ctx, key, ReadOptions{})
+}
+
+// GetSecretWithOptions is GetSecret with the record checks relaxed by opts.
+func (c *Client) GetSecretWithOptions(ctx context.Context, key string, opts ReadOptions) ([]byte, error) {
+	const op = "Client.GetSecret"
+	if opts.Version != "" && opts.Version != VersionCurrent {
+		return c.getSecretVersion(ctx, key, opts)
+	}
+	if hit, ok := c.plaintextCache.Get(key); ok {
+		if err := c.authorize(ctx, PermissionRead, hit.rec); err != nil {
+			return nil, withKey(err, op, key, hit.rec.Store)
+		}
+		if pt, ok := hit.pt.clone(); ok {
+			c.maybeRefresh(key, hit)
+			return pt, nil
+		}
+		// destroyed by a concurrent eviction; read it again
+	}
+	if c.negative.has(key) {
+		return nil, &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	rec, err := c.load(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.negative.add(key)
+		}
+		return nil, withKey(err, op, key, "")
+	}
+	if err := c.checkKey(key, rec); err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	// Authorize first: the status of a record is not for unauthorized callers.
+	if err := c.authorize(ctx, PermissionRead, rec); err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	if err := statusErr(rec.Status, opts.AllowDraft); err != nil {
+		return nil, &Error{Op: op, Key: key, Store: rec.Store, Err: err}
+	}
+
+	cs, err := c.store(rec.Store)
+	if err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+
+	pt, err := c.opens.Do(ctx, key+"|"+rec.Version, func(ctx context.Context) ([]byte, error) {
+		return c.open(ctx, cs, rec, true)
+	})
+	if err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	return pt, nil
+}
+
+// GetSecureSecret is GetSecret returning the plaintext as a SecureBytes the
+// caller owns: Destroy it once the secret has been used. It lives in
+// mlock'ed memory when the Client was built WithLockedMemory.
+func (c *Client) GetSecureSecret(ctx context.Context, key string) (*SecureBytes, error) {
+	pt, err := c.GetSecret(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(pt)
+	return newSecureBytes(pt, c.lockMemory), nil
+}
+
+// load reads the record for key from the repository, sharing the query with
+// concurrent loads of the same key.
+func (c *Client) load(ctx context.Context, key string) (*SecretRecord, error) {
+	return c.loads.Do(ctx, key, func(ctx context.Context) (*SecretRecord, error) {
+		rec, err := c.repo.GetSecret(ctx, key)
+		if err == nil && rec == nil {
+			err = ErrNotFound
+		}
+		return rec, err
+	})
+}
+
+// open unwraps the DEK of rec, fetches its ciphertext from cs and decrypts
+// it, in the split-field or Envelope format (see isEnvelope), caching the plaintext when cache is set and rec is active.
+func (c *Client) open(ctx context.Context, cs CiphertextStore, rec *SecretRecord, cache bool) ([]byte, error) {
+	// AAD + KMS EncryptionContext from the record, under its AADScheme
+	aad, encCtx, err := MakeRecordAADAndEncCtx(rec)
+	if err != nil {
+		return nil, err
+	}
+	open := c.openFields
+	if isEnvelope(rec) {
+		open = c.openEnvelope
 	}
-	rec, err := c.repo.GetSecret(ctx, key)
+	pt, err := open(ctx, cs, rec, aad, encCtx)
 	if err != nil {
 		return nil, err
 	}
-	if rec == nil {
-		return nil, fmt.Errorf("secret not found for key %q", key)
+	if cache && rec.Status == StatusActive {
+		c.plaintextCache.Set(rec.Key, &cachedSecret{rec: rec, pt: newSecureBytes(pt, c.lockMemory), fetched: time.Now()})
 	}
+	return pt, nil
+}
 
-	// AAD + KMS EncryptionContext from the record
-	aad, encCtx := MakeAADAndEncCtx(rec.TenantID, rec.Key)
+// openFields decrypts a record in the split-field format: IV, Tag,
+// WrappedDEK and Commitment on the record, Base64(ciphertext) in its store.
+func (c *Client) openFields(ctx context.Context, cs CiphertextStore, rec *SecretRecord, aad []byte, encCtx map[string]string) ([]byte, error) {
+	const op = "crypto.Open"
+	ci, err := c.cipher(op, rec.DEKAlg)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkKEKAlg(op, rec); err != nil {
+		return nil, err
+	}
+	commitment, err := base64.StdEncoding.DecodeString(rec.Commitment)
+	if err != nil {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "commitment base64: %v", err)
+	}
 
 	// Unwrap DEK
-	dek, err := c.kms.DecryptDEK(ctx, rec.WrappedDEK, encCtx, rec.KEKKeyID)
+	dek, err := c.keys.DecryptDEK(ctx, rec.WrappedDEK, encCtx, rec.KEKKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+	if err := verifyCommitment(op, ci, dek, commitment); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(op, rec.DEKAlg, ci, dek, ErrInvalidCiphertext)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get ciphertext (DB vs SSM)
-	valueB64 := rec.Value
-	if rec.Store == StoreAWSSSM {
-		valueB64, err = c.ssm.Get(ctx, rec.Key)
-		if err != nil {
-			return nil, err
-		}
+	// Get ciphertext from the record's store (DB, SSM, ...)
+	valueB64, err := cs.GetCiphertext(ctx, rec)
+	if err != nil {
+		return nil, err
 	}
+	return decryptAEAD(aead, valueB64, rec.IV, rec.Tag, aad)
+}
 
-	pt, err := decryptAESGCM(dek, valueB64, rec.IV, rec.Tag, aad)
+// openEnvelope decrypts a record whose store holds an Envelope. Everything
+// but the AAD and EncryptionContext comes from the envelope; a DEKAlg or
+// KEKKeyID set on the record must match it, so a store writer cannot swap in
+// an envelope under a weaker cipher or another KEK.
+func (c *Client) openEnvelope(ctx context.Context, cs CiphertextStore, rec *SecretRecord, aad []byte, encCtx map[string]string) ([]byte, error) {
+	const op = "crypto.OpenEnvelope"
+	valueB64, err := cs.GetCiphertext(ctx, rec)
 	if err != nil {
 		return nil, err
 	}
-	c.plaintextCache.Set(key, pt)
-	return pt, nil
+	e, err := ParseEnvelope(valueB64)
+	if err != nil {
+		return nil, err
+	}
+	if rec.DEKAlg != "" && e.DEKAlg != rec.DEKAlg {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "envelope dek algorithm %q, record has %q", e.DEKAlg, rec.DEKAlg)
+	}
+	if rec.KEKKeyID != "" && e.KEKKeyID != rec.KEKKeyID {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "envelope kek key id %q, record has %q", e.KEKKeyID, rec.KEKKeyID)
+	}
+	ci, err := c.cipher(op, e.DEKAlg)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkKEKAlg(op, rec); err != nil {
+		return nil, err
+	}
+	dek, err := c.keys.DecryptDEK(ctx, base64.StdEncoding.EncodeToString(e.WrappedDEK), encCtx, e.KEKKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+	return e.open(op, ci, dek, aad)
+}
+
+// Invalidate drops key from the plaintext cache and from the caches of the
+// repository and ciphertext stores, so the next read goes to the source. When
+// the key is in the plaintext cache its DEK is also dropped from the
+// KeyUnwrapper's cache. Use it (directly or through an InvalidationListener)
+// when a record changes in another process.
+func (c *Client) Invalidate(key string) {
+	if hit, ok := c.plaintextCache.Get(key); ok {
+		c.purge(hit.rec)
+	}
+	c.plaintextCache.Delete(key)
+	c.negative.forget(key)
+	if inv, ok := c.repo.(KeyInvalidator); ok {
+		inv.Invalidate(key)
+	}
+	for _, cs := range c.stores {
+		if inv, ok := cs.(KeyInvalidator); ok {
+			inv.Invalidate(key)
+		}
+	}
+}
+
+// NegativeCacheStats reports on the Client's negative cache (see
+// WithNegativeCache).
+func (c *Client) NegativeCacheStats() NegativeCacheStats {
+	return c.negative.stats()
+}
+
+// PurgeNegativeCache forgets every key remembered as not found. It does not
+// touch the repository's own negative cache.
+func (c *Client) PurgeNegativeCache() {
+	c.negative.purge()
+}
+
+// Purge drops every cached plaintext and negative entry, and purges the
+// repository, key and ciphertext store caches that support it.
+func (c *Client) Purge() {
+	c.plaintextCache.Clear()
+	c.negative.purge()
+	if p, ok := c.repo.(cachePurger); ok {
+		p.Purge()
+	}
+	if p, ok := c.keys.(cachePurger); ok {
+		p.Purge()
+	}
+	for _, cs := range c.stores {
+		if p, ok := cs.(cachePurger); ok {
+			p.Purge()
+		}
+	}
+}
+
+// Close stops background refreshes, waits for running ones to finish and
+// zeroes every cached plaintext. The Client remains usable for reads
+// afterwards, without refresh. Close is safe to call more than once.
+func (c *Client) Close() error {
+	c.bgMu.Lock()
+	if c.bgCancel != nil {
+		c.bgCancel()
+	}
+	c.bgMu.Unlock()
+	c.bgWG.Wait()
+	c.plaintextCache.Clear()
+	return nil
+}
+
+func (c *Client) authorize(ctx context.Context, perm string, rec *SecretRecord) error {
+	if c.authz == nil {
+		return nil
+	}
+	return c.authz.Authorize(ctx, perm, rec)
 }
@@ -80,7 +80,7 @@ func TestClient_GetSecret_FromSSM_AndCache(t *testing.T) {
 
 	repo := &stubRepo{rec: rec}
 
-	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute)
+	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute, openACL)
 
 	pt, err := client.GetSecret(ctx, key)
 	require.NoError(t, err)
@@ -138,7 +138,7 @@ func TestClient_GetSecret_FromDB_AndCache(t *testing.T) {
 	}
 
 	repo := &stubRepo{rec: rec}
-	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute)
+	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute, openACL)
 
 	pt, err := client.GetSecret(ctx, key)
 	require.NoError(t, err)
@@ -152,3 +152,37 @@ func TestClient_GetSecret_FromDB_AndCache(t *testing.T) {
 	require.Equal(t, 1, kmsFake.Calls)
 	require.Equal(t, 0, ssmFake.Calls)
 }
+
+func TestClient_PlaintextCacheLimits(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+
+	mem := vault.NewInMemoryRepo()
+	keys := make([]string, 2)
+	for i := range keys {
+		keys[i] = vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+		aad, _ := vault.MakeAADAndEncCtx(tenantID, keys[i])
+		iv, ct, tag, err := fakes.EncryptWithDEK(dek, []byte("secret"), aad)
+		require.NoError(t, err)
+		mem.Put(&vault.SecretRecord{
+			ID: keySecretID(t, keys[i]), TenantID: tenantID, Key: keys[i], Store: vault.StoreDSVault, Status: vault.StatusActive,
+			Value: ct, IV: iv, Tag: tag, WrappedDEK: "V1JBUFBFRA==",
+		})
+	}
+	gate := make(chan struct{})
+	close(gate)
+	repo := &gatedRepo{SecretRepository: mem, gate: gate}
+	client := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{Plaintext: dek}, 16, time.Minute), nil, time.Minute,
+		vault.WithPlaintextCacheLimits(1, 0), openACL)
+
+	for _, k := range []string{keys[0], keys[0], keys[1], keys[0]} {
+		_, err := client.GetSecret(ctx, k)
+		require.NoError(t, err)
+	}
+	// keys[0] hit once, then was evicted by keys[1].
+	require.Equal(t, int32(3), repo.calls.Load())
+}
@@ -0,0 +1,91 @@
+package vault
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+)
+
+// DEKAlgAES256GCMCommit is key-committing AES-256-GCM. Plain GCM is not
+// key-committing: a crafted ciphertext can open under two different DEKs,
+// and WrappedDEK and the ciphertext may live in different stores (Postgres
+// and SSM). Records sealed with it carry SecretRecord.Commitment, which is
+// checked against the unwrapped DEK before the ciphertext is opened, so a
+// ciphertext opens under one DEK only. It is opt-in via PutOptions.DEKAlg.
+//
+//	key        = HKDF-SHA256(DEK, salt="", info="ds-vault/aes256-gcm-commit/key", 32)
+//	commitment = HKDF-SHA256(DEK, salt="", info="ds-vault/aes256-gcm-commit/commitment", 32)
+//	ciphertext = AES-256-GCM(key, IV, plaintext, AAD)
+const DEKAlgAES256GCMCommit = "AES256-GCM-COMMIT"
+
+// CommittingCipher is a Cipher whose ciphertexts are bound to one DEK by a
+// commitment stored in SecretRecord.Commitment. Client checks it before
+// NewAEAD's AEAD opens anything.
+type CommittingCipher interface {
+	Cipher
+	// Commit returns the commitment to dek.
+	Commit(dek []byte) ([]byte, error)
+}
+
+// AES256GCMCommit is the CommittingCipher registered for
+// DEKAlgAES256GCMCommit.
+var AES256GCMCommit CommittingCipher = hkdfCommitCipher{AES256GCM, "ds-vault/aes256-gcm-commit/"}
+
+// hkdfCommitCipher derives the inner cipher's key and the commitment from
+// the DEK with HKDF-SHA256 under distinct labels.
+type hkdfCommitCipher struct {
+	inner Cipher
+	label string
+}
+
+func (c hkdfCommitCipher) KeySize() int { return c.inner.KeySize() }
+
+func (c hkdfCommitCipher) NewAEAD(dek []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, dek, nil, c.label+"key", c.inner.KeySize())
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	return c.inner.NewAEAD(key)
+}
+
+func (c hkdfCommitCipher) Commit(dek []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, dek, nil, c.label+"commitment", sha256.Size)
+}
+
+// commit returns the commitment to dek when ci is a CommittingCipher, and
+// nil otherwise.
+func commit(op string, ci Cipher, dek []byte) ([]byte, error) {
+	cc, ok := ci.(CommittingCipher)
+	if !ok {
+		return nil, nil
+	}
+	sum, err := cc.Commit(dek)
+	if err != nil {
+		return nil, &Error{Op: op, Err: err}
+	}
+	return sum, nil
+}
+
+// verifyCommitment checks commitment against dek when ci is a
+// CommittingCipher. A missing commitment is ErrInvalidCiphertext, one for
+// another DEK ErrAuthenticationFailed.
+func verifyCommitment(op string, ci Cipher, dek, commitment []byte) error {
+	cc, ok := ci.(CommittingCipher)
+	if !ok {
+		return nil
+	}
+	if len(commitment) == 0 {
+		return errorf(op, "", ErrInvalidCiphertext, "missing key commitment")
+	}
+	want, err := cc.Commit(dek)
+	if err != nil {
+		return &Error{Op: op, Err: err}
+	}
+	if subtle.ConstantTimeCompare(commitment, want) != 1 {
+		return &Error{Op: op, Err: fmt.Errorf("%w: key commitment mismatch", ErrAuthenticationFailed)}
+	}
+	return nil
+}
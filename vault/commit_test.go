@@ -0,0 +1,108 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestCipher_AES256GCMCommit_Vectors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		dek, iv, plaintext, aad []byte
+		commitment, sealed      string
+	}{
+		{
+			dek:        unhex(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+			iv:         unhex(t, "000102030405060708090a0b"),
+			plaintext:  []byte("committed secret"),
+			aad:        []byte("ds-vault"),
+			commitment: "1a7b2fac36f9bdbd989844e485331aee1b1c84a089de6a45cb198d41a1854ec0",
+			sealed:     "ea46aa8cdbe8896468e13468c56755d248ce1eeba9a12fd111ab81ca8738d3f1",
+		},
+		{
+			dek:        bytes.Repeat([]byte{0x42}, 32),
+			iv:         bytes.Repeat([]byte{0xff}, 12),
+			commitment: "3bb417b052bef12605bf7770ff626216b55aa5f89d85ab2d6c797bd61af3b3cd",
+			sealed:     "cb1800f7f336311c578c048e45c87bf4",
+		},
+	} {
+		commitment, err := vault.AES256GCMCommit.Commit(tc.dek)
+		require.NoError(t, err)
+		require.Equal(t, tc.commitment, hex.EncodeToString(commitment))
+
+		aead, err := vault.AES256GCMCommit.NewAEAD(tc.dek)
+		require.NoError(t, err)
+		sealed := aead.Seal(nil, tc.iv, tc.plaintext, tc.aad)
+		require.Equal(t, tc.sealed, hex.EncodeToString(sealed))
+		pt, err := aead.Open(nil, tc.iv, sealed, tc.aad)
+		require.NoError(t, err)
+		require.Equal(t, string(tc.plaintext), string(pt))
+	}
+}
+
+func TestClient_CommittedDEKAlg(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+	kmsFake := &fakes.KMS{Plaintext: dek}
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{Values: map[string]string{}}, 16, time.Minute), time.Minute, openACL)
+
+	rec, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{
+		TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "alias/ds-vault", DEKAlg: vault.DEKAlgAES256GCMCommit,
+	})
+	require.NoError(t, err)
+	require.Equal(t, vault.DEKAlgAES256GCMCommit, rec.DEKAlg)
+	require.NotEmpty(t, rec.Commitment)
+
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", string(pt))
+
+	// A WrappedDEK that unwraps to another DEK is rejected by the commitment
+	// check, before the ciphertext is opened.
+	other := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{Plaintext: bytes.Repeat([]byte{1}, 32)}, 16, time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{Values: map[string]string{}}, 16, time.Minute), time.Minute, openACL)
+	_, err = other.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrAuthenticationFailed)
+	require.ErrorContains(t, err, "commitment")
+
+	for commitment, want := range map[string]error{
+		"":                 vault.ErrInvalidCiphertext,
+		"not base64!":      vault.ErrInvalidCiphertext,
+		"AAAAAAAAAAAAAA==": vault.ErrAuthenticationFailed,
+	} {
+		bad := *rec
+		bad.Commitment = commitment
+		repo.Put(&bad)
+		client.Invalidate(key)
+		_, err = client.GetSecret(ctx, key)
+		require.ErrorIs(t, err, want, commitment)
+	}
+	repo.Put(rec)
+
+	// RotateSecret keeps DEKAlg, so rotated records stay committed.
+	rec, err = client.RotateSecret(ctx, key, []byte("n3w"))
+	require.NoError(t, err)
+	require.Equal(t, vault.DEKAlgAES256GCMCommit, rec.DEKAlg)
+	require.NotEmpty(t, rec.Commitment)
+	pt, err = client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "n3w", string(pt))
+}
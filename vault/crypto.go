@@ -1,40 +1,55 @@
 package vault
 
 import (
-	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 )
 
-func decryptAESGCM(dek []byte, valueB64, ivB64, tagB64 string, aad []byte) ([]byte, error) {
+// encryptAEAD seals plaintext with a using a random nonce. It returns the
+// nonce and ciphertext || tag.
+func encryptAEAD(a cipher.AEAD, plaintext, aad []byte) ([]byte, []byte, error) {
+	iv := make([]byte, a.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, &Error{Op: "crypto.Seal", Err: fmt.Errorf("iv: %w", err)}
+	}
+	return iv, a.Seal(nil, iv, plaintext, aad), nil
+}
+
+func decryptAEAD(a cipher.AEAD, valueB64, ivB64, tagB64 string, aad []byte) ([]byte, error) {
+	const op = "crypto.Open"
 	ct, err := base64.StdEncoding.DecodeString(valueB64)
 	if err != nil {
-		return nil, fmt.Errorf("ciphertext base64: %w", err)
+		return nil, errorf(op, "", ErrInvalidCiphertext, "ciphertext base64: %v", err)
 	}
 	iv, err := base64.StdEncoding.DecodeString(ivB64)
 	if err != nil {
-		return nil, fmt.Errorf("iv base64: %w", err)
+		return nil, errorf(op, "", ErrInvalidCiphertext, "iv base64: %v", err)
 	}
 	tag, err := base64.StdEncoding.DecodeString(tagB64)
 	if err != nil {
-		return nil, fmt.Errorf("tag base64: %w", err)
+		return nil, errorf(op, "", ErrInvalidCiphertext, "tag base64: %v", err)
 	}
-	block, err := aes.NewCipher(dek)
-	if err != nil {
-		return nil, err
+	if len(tag) != a.Overhead() {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "bad tag size: %d", len(tag))
 	}
-	g, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
+	// Go writer stored tag separately; append before Open
+	return openAEAD(a, iv, append(ct, tag...), aad)
+}
+
+// openAEAD opens sealed (ciphertext || tag) with a.
+func openAEAD(a cipher.AEAD, iv, sealed, aad []byte) ([]byte, error) {
+	const op = "crypto.Open"
+	if len(iv) != a.NonceSize() {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "bad iv size: %d", len(iv))
 	}
-	if len(iv) != g.NonceSize() {
-		return nil, fmt.Errorf("bad iv size: %d", len(iv))
+	if len(sealed) < a.Overhead() {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "ciphertext shorter than tag")
 	}
-	// Go writer stored tag separately; append before Open
-	pt, err := g.Open(nil, iv, append(ct, tag...), aad)
+	pt, err := a.Open(nil, iv, sealed, aad)
 	if err != nil {
-		return nil, fmt.Errorf("gcm open: %w", err)
+		return nil, &Error{Op: op, Err: ErrAuthenticationFailed}
 	}
 	return pt, nil
 }
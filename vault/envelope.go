@@ -0,0 +1,221 @@
+package vault
+
+import (
+	"context"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// EnvelopeVersion1 is the only envelope version so far.
+const EnvelopeVersion1 = 1
+
+// envelopeAlgIDs are the DEKAlg identifiers envelopes use. Ciphers
+// registered under other names cannot be written as envelopes.
+var envelopeAlgIDs = map[string]byte{
+	DEKAlgAES256GCM:         1,
+	DEKAlgXChaCha20Poly1305: 2,
+	DEKAlgAES256GCMSIV:      3,
+	DEKAlgAES256GCMCommit:   4,
+}
+
+// Envelope is a self-describing ciphertext: everything needed to decrypt a
+// secret, apart from the KEK, AAD and EncryptionContext, in one blob. It is
+// stored as Base64 of
+//
+//	version     1 byte (EnvelopeVersion1)
+//	alg id      1 byte (1 AES256-GCM, 2 XCHACHA20-POLY1305, 3 AES256-GCM-SIV, 4 AES256-GCM-COMMIT)
+//	key id      uint16 big-endian length + bytes (KEK key id/arn)
+//	wrapped DEK uint16 big-endian length + bytes
+//	nonce       1 byte length + bytes
+//	commitment  1 byte length + bytes (empty unless the cipher is committing)
+//	ciphertext  the rest: ciphertext || tag
+//
+// The version and alg id bytes are authenticated as a prefix of the AAD.
+// Client reads envelopes from any store for records with neither IV nor
+// WrappedDEK (see PutOptions.Envelope), so a ciphertext can be moved between
+// stores as a single unit.
+type Envelope struct {
+	Version    byte
+	DEKAlg     string
+	KEKKeyID   string
+	WrappedDEK []byte
+	Nonce      []byte
+	Commitment []byte
+	Ciphertext []byte // ciphertext || tag
+}
+
+// isEnvelope reports whether rec's store holds an Envelope rather than
+// Base64(ciphertext): envelope records carry neither IV nor WrappedDEK.
+func isEnvelope(rec *SecretRecord) bool {
+	return rec.IV == "" && rec.WrappedDEK == ""
+}
+
+// ParseEnvelope decodes a Base64 envelope. Malformed envelopes, unknown
+// versions and unknown alg ids fail with ErrInvalidCiphertext.
+func ParseEnvelope(envelopeB64 string) (*Envelope, error) {
+	const op = "ParseEnvelope"
+	b, err := base64.StdEncoding.DecodeString(envelopeB64)
+	if err != nil {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "envelope base64: %v", err)
+	}
+	if len(b) < 2 {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "envelope too short")
+	}
+	e := &Envelope{Version: b[0]}
+	if e.Version != EnvelopeVersion1 {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "unknown envelope version %d", e.Version)
+	}
+	for alg, id := range envelopeAlgIDs {
+		if id == b[1] {
+			e.DEKAlg = alg
+		}
+	}
+	if e.DEKAlg == "" {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "unknown envelope alg id %d", b[1])
+	}
+	r := envelopeReader{b: b[2:]}
+	e.KEKKeyID = string(r.next(2))
+	e.WrappedDEK = r.next(2)
+	e.Nonce = r.next(1)
+	e.Commitment = r.next(1)
+	if r.short {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "envelope truncated")
+	}
+	e.Ciphertext = r.b
+	return e, nil
+}
+
+// envelopeReader reads length-prefixed fields, remembering whether it ran
+// out of input.
+type envelopeReader struct {
+	b     []byte
+	short bool
+}
+
+func (r *envelopeReader) next(lenSize int) []byte {
+	if len(r.b) < lenSize {
+		r.short = true
+		return nil
+	}
+	n := int(r.b[0])
+	if lenSize == 2 {
+		n = int(binary.BigEndian.Uint16(r.b))
+	}
+	r.b = r.b[lenSize:]
+	if len(r.b) < n {
+		r.short = true
+		return nil
+	}
+	f := r.b[:n:n]
+	r.b = r.b[n:]
+	return f
+}
+
+// Encode returns the Base64 envelope. DEKAlgs without an envelope alg id
+// fail with ErrUnknownAlgorithm, oversized fields with ErrInvalidArgument.
+func (e *Envelope) Encode() (string, error) {
+	const op = "Envelope.Encode"
+	if e.Version != EnvelopeVersion1 {
+		return "", errorf(op, "", ErrInvalidArgument, "unknown envelope version %d", e.Version)
+	}
+	id, ok := envelopeAlgIDs[e.DEKAlg]
+	if !ok {
+		return "", &Error{Op: op, Err: fmt.Errorf("%w: dek algorithm %q has no envelope id", ErrUnknownAlgorithm, e.DEKAlg)}
+	}
+	switch {
+	case len(e.KEKKeyID) > 0xffff, len(e.WrappedDEK) > 0xffff:
+		return "", errorf(op, "", ErrInvalidArgument, "key id or wrapped dek too long")
+	case len(e.Nonce) > 0xff, len(e.Commitment) > 0xff:
+		return "", errorf(op, "", ErrInvalidArgument, "nonce or commitment too long")
+	}
+	b := make([]byte, 0, 8+len(e.KEKKeyID)+len(e.WrappedDEK)+len(e.Nonce)+len(e.Commitment)+len(e.Ciphertext))
+	b = append(b, e.Version, id)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.KEKKeyID)))
+	b = append(b, e.KEKKeyID...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.WrappedDEK)))
+	b = append(b, e.WrappedDEK...)
+	b = append(b, byte(len(e.Nonce)))
+	b = append(b, e.Nonce...)
+	b = append(b, byte(len(e.Commitment)))
+	b = append(b, e.Commitment...)
+	b = append(b, e.Ciphertext...)
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// aad returns the AAD the envelope's ciphertext is sealed with: the version
+// and alg id bytes followed by aad.
+func (e *Envelope) aad(aad []byte) []byte {
+	return append([]byte{e.Version, envelopeAlgIDs[e.DEKAlg]}, aad...)
+}
+
+// SealEnvelope envelope-encrypts plaintext without a Client or repository:
+// it generates a DEK under keyID bound to encCtx, seals plaintext with the
+// built-in cipher for dekAlg (DEKAlgAES256GCM when empty) and aad, and
+// returns the Base64 envelope.
+func SealEnvelope(ctx context.Context, kp KeyProvider, keyID, dekAlg string, plaintext []byte, encCtx map[string]string, aad []byte) (string, error) {
+	const op = "SealEnvelope"
+	if dekAlg == "" {
+		dekAlg = DEKAlgAES256GCM
+	}
+	ci, ok := defaultCiphers()[dekAlg]
+	if !ok {
+		return "", &Error{Op: op, Err: fmt.Errorf("%w: dek algorithm %q", ErrUnknownAlgorithm, dekAlg)}
+	}
+	dek, wrapped, err := kp.GenerateDEK(ctx, keyID, encCtx)
+	if err != nil {
+		return "", err
+	}
+	defer clear(dek)
+	a, err := newAEAD(op, dekAlg, ci, dek, ErrInvalidArgument)
+	if err != nil {
+		return "", err
+	}
+	e := &Envelope{Version: EnvelopeVersion1, DEKAlg: dekAlg, KEKKeyID: keyID}
+	if e.WrappedDEK, err = base64.StdEncoding.DecodeString(wrapped); err != nil {
+		return "", errorf(op, "", ErrInvalidCiphertext, "wrapped dek base64: %v", err)
+	}
+	if e.Commitment, err = commit(op, ci, dek); err != nil {
+		return "", err
+	}
+	if err := e.seal(a, plaintext, aad); err != nil {
+		return "", err
+	}
+	return e.Encode()
+}
+
+// OpenEnvelope decrypts a Base64 envelope sealed with SealEnvelope (or by a
+// Client with PutOptions.Envelope), unwrapping its DEK with ku under encCtx.
+func OpenEnvelope(ctx context.Context, ku KeyUnwrapper, envelopeB64 string, encCtx map[string]string, aad []byte) ([]byte, error) {
+	const op = "OpenEnvelope"
+	e, err := ParseEnvelope(envelopeB64)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := ku.DecryptDEK(ctx, base64.StdEncoding.EncodeToString(e.WrappedDEK), encCtx, e.KEKKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+	return e.open(op, defaultCiphers()[e.DEKAlg], dek, aad)
+}
+
+// seal encrypts plaintext into e with a, under e's header and aad.
+func (e *Envelope) seal(a cipher.AEAD, plaintext, aad []byte) error {
+	var err error
+	e.Nonce, e.Ciphertext, err = encryptAEAD(a, plaintext, e.aad(aad))
+	return err
+}
+
+// open checks the key commitment and decrypts e with ci under dek.
+func (e *Envelope) open(op string, ci Cipher, dek, aad []byte) ([]byte, error) {
+	if err := verifyCommitment(op, ci, dek, e.Commitment); err != nil {
+		return nil, err
+	}
+	a, err := newAEAD(op, e.DEKAlg, ci, dek, ErrInvalidCiphertext)
+	if err != nil {
+		return nil, err
+	}
+	return openAEAD(a, e.Nonce, e.Ciphertext, e.aad(aad))
+}
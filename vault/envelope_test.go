@@ -0,0 +1,284 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestEnvelope_SealOpen(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	encCtx := map[string]string{"tenant_id": "t1"}
+	for _, alg := range []string{
+		vault.DEKAlgAES256GCM, vault.DEKAlgXChaCha20Poly1305, vault.DEKAlgAES256GCMSIV, vault.DEKAlgAES256GCMCommit,
+	} {
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+			kmsFake := &fakes.KMS{ExpectEncCtx: encCtx}
+			kp := vault.NewKMSProvider(kmsFake, 16, time.Minute)
+
+			envB64, err := vault.SealEnvelope(ctx, kp, "alias/ds-vault", alg, []byte("s3cr3t"), encCtx, []byte("aad"))
+			require.NoError(t, err)
+
+			env, err := vault.ParseEnvelope(envB64)
+			require.NoError(t, err)
+			require.EqualValues(t, vault.EnvelopeVersion1, env.Version)
+			require.Equal(t, alg, env.DEKAlg)
+			require.Equal(t, "alias/ds-vault", env.KEKKeyID)
+			require.Equal(t, append([]byte("WRAPPED:"), kmsFake.Plaintext...), env.WrappedDEK)
+			require.Equal(t, alg == vault.DEKAlgAES256GCMCommit, len(env.Commitment) > 0)
+			again, err := env.Encode()
+			require.NoError(t, err)
+			require.Equal(t, envB64, again)
+
+			pt, err := vault.OpenEnvelope(ctx, kp, envB64, encCtx, []byte("aad"))
+			require.NoError(t, err)
+			require.Equal(t, "s3cr3t", string(pt))
+
+			_, err = vault.OpenEnvelope(ctx, kp, envB64, encCtx, []byte("other"))
+			require.ErrorIs(t, err, vault.ErrAuthenticationFailed)
+		})
+	}
+}
+
+func TestEnvelope_Malformed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	kp := vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute)
+
+	envB64, err := vault.SealEnvelope(ctx, kp, "alias/ds-vault", "", []byte("s3cr3t"), nil, nil)
+	require.NoError(t, err)
+	raw, err := base64.StdEncoding.DecodeString(envB64)
+	require.NoError(t, err)
+	edit := func(f func(b []byte) []byte) string {
+		return base64.StdEncoding.EncodeToString(f(append([]byte(nil), raw...)))
+	}
+
+	for name, bad := range map[string]string{
+		"empty":       "",
+		"not base64":  "not base64!",
+		"version":     edit(func(b []byte) []byte { b[0] = 2; return b }),
+		"alg id":      edit(func(b []byte) []byte { b[1] = 99; return b }),
+		"truncated":   edit(func(b []byte) []byte { return b[:5] }),
+		"key id len":  edit(func(b []byte) []byte { b[2], b[3] = 0xff, 0xff; return b }),
+		"no tag room": edit(func(b []byte) []byte { return b[:len(b)-len("s3cr3t")-1] }),
+	} {
+		_, err := vault.OpenEnvelope(ctx, kp, bad, nil, nil)
+		require.ErrorIs(t, err, vault.ErrInvalidCiphertext, name)
+	}
+
+	// The alg id is authenticated: relabelling AES256-GCM as AES256-GCM-SIV
+	// (same key and nonce sizes) does not open.
+	_, err = vault.OpenEnvelope(ctx, kp, edit(func(b []byte) []byte { b[1] = 3; return b }), nil, nil)
+	require.ErrorIs(t, err, vault.ErrAuthenticationFailed)
+
+	_, err = vault.SealEnvelope(ctx, kp, "alias/ds-vault", "ROT13", []byte("x"), nil, nil)
+	require.ErrorIs(t, err, vault.ErrUnknownAlgorithm)
+	_, err = (&vault.Envelope{Version: vault.EnvelopeVersion1, DEKAlg: "ROT13"}).Encode()
+	require.ErrorIs(t, err, vault.ErrUnknownAlgorithm)
+}
+
+func TestClient_EnvelopeDowngradeRejected(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	kmsFake := &fakes.KMS{}
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	repo := vault.NewInMemoryRepo()
+	kp := vault.NewKMSProvider(kmsFake, 16, time.Minute)
+	client := vault.NewClient(repo, kp, vault.NewSSMProvider(ssmFake, 16, time.Minute), time.Minute, openACL)
+
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	rec, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{
+		TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "alias/ds-vault", DEKAlg: vault.DEKAlgAES256GCMCommit, Envelope: true,
+	})
+	require.NoError(t, err)
+	aad, encCtx, err := vault.MakeRecordAADAndEncCtx(rec)
+	require.NoError(t, err)
+
+	for name, forge := range map[string]struct{ kek, alg string }{
+		"cipher": {kek: "alias/ds-vault", alg: vault.DEKAlgAES256GCM},
+		"kek":    {kek: "alias/other", alg: vault.DEKAlgAES256GCMCommit},
+	} {
+		// Same DEK (the fake returns it again) and AAD, so the forged
+		// envelope authenticates on its own; only the record rejects it.
+		forged, err := vault.SealEnvelope(ctx, kp, forge.kek, forge.alg, []byte("forged"), encCtx, aad)
+		require.NoError(t, err, name)
+		env, err := vault.ParseEnvelope(forged)
+		require.NoError(t, err, name)
+		require.Equal(t, forge.alg == vault.DEKAlgAES256GCMCommit, len(env.Commitment) > 0, name)
+		pt, err := vault.OpenEnvelope(ctx, kp, forged, encCtx, aad)
+		require.NoError(t, err, name)
+		require.Equal(t, "forged", string(pt), name)
+
+		ssmFake.Values[key] = forged
+		client.Invalidate(key)
+		_, err = client.GetSecret(ctx, key)
+		require.ErrorIs(t, err, vault.ErrInvalidCiphertext, name)
+	}
+}
+
+func TestClient_EnvelopeRecords(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	kmsFake := &fakes.KMS{}
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	repo := vault.NewInMemoryRepo()
+	kp := vault.NewKMSProvider(kmsFake, 16, time.Minute)
+	ssmProv := vault.NewSSMProvider(ssmFake, 16, time.Minute)
+	client := vault.NewClient(repo, kp, ssmProv, time.Minute, openACL)
+
+	keys := map[vault.Store]string{}
+	for _, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM} {
+		key := vault.MakeKey(uuid.New(), tenantID, string(store), string(vault.EnvDev), "ds", "vault")
+		keys[store] = key
+		rec, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{
+			TenantID: tenantID, Store: store, KEKKeyID: "alias/ds-vault", DEKAlg: vault.DEKAlgAES256GCMCommit, Envelope: true,
+		})
+		require.NoError(t, err)
+		require.Empty(t, rec.IV)
+		require.Empty(t, rec.Tag)
+		require.Empty(t, rec.WrappedDEK)
+		require.Empty(t, rec.Commitment)
+		require.Equal(t, vault.DEKAlgAES256GCMCommit, rec.DEKAlg)
+
+		pt, err := client.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "s3cr3t", string(pt))
+
+		// Rotation keeps the envelope format.
+		rec, err = client.RotateSecret(ctx, key, []byte("n3w"))
+		require.NoError(t, err)
+		require.Empty(t, rec.IV)
+		require.Empty(t, rec.WrappedDEK)
+		pt, err = client.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "n3w", string(pt))
+	}
+	env, err := vault.ParseEnvelope(ssmFake.Values[keys[vault.StoreAWSSSM]])
+	require.NoError(t, err)
+	require.Equal(t, "alias/ds-vault", env.KEKKeyID)
+
+	// Split-field records live alongside envelope records.
+	legacyKey := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	legacy, err := client.PutSecret(ctx, legacyKey, []byte("legacy"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+	require.NotEmpty(t, legacy.IV)
+	require.NotEmpty(t, legacy.WrappedDEK)
+	pt, err := client.GetSecret(ctx, legacyKey)
+	require.NoError(t, err)
+	require.Equal(t, "legacy", string(pt))
+
+	// The SSM value is the whole ciphertext: copied to another parameter
+	// store, it opens under a record carrying only metadata.
+	rec, err := repo.GetSecret(ctx, keys[vault.StoreAWSSSM])
+	require.NoError(t, err)
+	moved := vault.NewInMemoryRepo()
+	require.NoError(t, moved.CreateSecret(ctx, &vault.SecretRecord{
+		ID: rec.ID, TenantID: tenantID, Key: rec.Key, Store: vault.StoreAWSSSM, Status: vault.StatusActive,
+		Version: rec.Version, AADScheme: vault.AADSchemeV2,
+	}))
+	otherSSM := &fakes.SSM{Values: map[string]string{rec.Key: ssmFake.Values[rec.Key]}}
+	pt, err = vault.NewClient(moved, kp, vault.NewSSMProvider(otherSSM, 16, time.Minute), time.Minute, openACL).GetSecret(ctx, rec.Key)
+	require.NoError(t, err)
+	require.Equal(t, "n3w", string(pt))
+
+	// The Rewrapper re-wraps the current envelopes in their stores; the
+	// older v1 envelopes cannot be rewritten and are reported.
+	rep, err := vault.NewRewrapper(repo, kp, vault.WithRewrapStore(vault.StoreAWSSSM, ssmProv)).
+		Run(ctx, vault.RewrapOptions{TenantID: tenantID, DestinationKeyID: "alias/next"})
+	require.NoError(t, err)
+	require.Equal(t, 1, rep.Rewrapped)
+	require.Len(t, rep.Failures, 2)
+	for _, f := range rep.Failures {
+		require.ErrorIs(t, f.Err, vault.ErrNotSupported)
+		require.ErrorContains(t, f.Err, "v1")
+	}
+	for _, key := range keys {
+		rec, err := repo.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "alias/next", rec.KEKKeyID)
+		client.Invalidate(key)
+		pt, err := client.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "n3w", string(pt))
+	}
+}
+
+func TestRewrapper_EnvelopeRecords(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	kmsFake := &fakes.KMS{}
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	repo := vault.NewInMemoryRepo()
+	kp := vault.NewKMSProvider(kmsFake, 16, time.Minute)
+	ssmProv := vault.NewSSMProvider(ssmFake, 16, time.Minute)
+	client := vault.NewClient(repo, kp, ssmProv, time.Minute, openACL)
+
+	keys := map[vault.Store]string{}
+	for _, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM} {
+		key := vault.MakeKey(uuid.New(), tenantID, string(store), string(vault.EnvDev), "ds", "vault")
+		keys[store] = key
+		_, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{
+			TenantID: tenantID, Store: store, KEKKeyID: "alias/ds-vault", Envelope: true,
+		})
+		require.NoError(t, err)
+	}
+
+	// Without a writer for SSM, that record fails; the DB one is re-wrapped.
+	rw := vault.NewRewrapper(repo, kp)
+	rep, err := rw.Run(ctx, vault.RewrapOptions{TenantID: tenantID, DestinationKeyID: "alias/next"})
+	require.NoError(t, err)
+	require.Equal(t, 1, rep.Rewrapped)
+	require.Len(t, rep.Failures, 1)
+	require.Equal(t, keys[vault.StoreAWSSSM], rep.Failures[0].Key)
+	require.ErrorIs(t, rep.Failures[0].Err, vault.ErrUnknownStore)
+
+	rw = vault.NewRewrapper(repo, kp, vault.WithRewrapStore(vault.StoreAWSSSM, ssmProv))
+	rep, err = rw.Run(ctx, vault.RewrapOptions{TenantID: tenantID, DestinationKeyID: "alias/next"})
+	require.NoError(t, err)
+	require.Empty(t, rep.Failures)
+	require.Equal(t, 1, rep.Rewrapped)
+	require.Equal(t, 1, rep.Skipped)
+
+	for store, key := range keys {
+		rec, err := repo.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "alias/next", rec.KEKKeyID, store)
+		require.Empty(t, rec.WrappedDEK, store)
+
+		valueB64 := rec.Value
+		if store == vault.StoreAWSSSM {
+			valueB64 = ssmFake.Values[key]
+			require.Equal(t, "2", rec.StoreVersion, "new parameter version is pinned")
+			require.Empty(t, rec.Metadata.Data, "metadata is left to the caller")
+		}
+		env, err := vault.ParseEnvelope(valueB64)
+		require.NoError(t, err, store)
+		require.Equal(t, "alias/next", env.KEKKeyID, store)
+		require.Equal(t, "REWRAPPED:alias/next:WRAPPED:"+string(kmsFake.Plaintext), string(env.WrappedDEK), store)
+
+		client.Invalidate(key)
+		pt, err := client.GetSecret(ctx, key)
+		require.NoError(t, err, store)
+		require.Equal(t, "s3cr3t", string(pt), store)
+
+		versions, err := repo.ListVersions(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "alias/next", versions[len(versions)-1].KEKKeyID, store)
+	}
+}
@@ -0,0 +1,180 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// Sentinel errors shared by the Client, repositories, providers and the
+// crypto layer. Every error the SDK returns wraps at most one of them, so
+// callers can branch with errors.Is (e.g. ErrNotFound -> 404,
+// ErrAccessDenied -> 403, ErrThrottled/ErrUnavailable -> 503).
+var (
+	// ErrNotFound: no secret record exists for the key.
+	ErrNotFound = errors.New("secret not found")
+	// ErrAlreadyExists: a record for the key exists already.
+	ErrAlreadyExists = errors.New("secret already exists")
+	// ErrConflict: an optimistic-concurrency precondition did not hold.
+	ErrConflict = errors.New("secret changed concurrently")
+	// ErrInvalidArgument: the caller passed an unusable value.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrNotSupported: the injected repository or provider lacks the
+	// capability the operation needs.
+	ErrNotSupported = errors.New("operation not supported")
+	// ErrUnknownStore: no CiphertextStore is registered for SecretRecord.Store.
+	ErrUnknownStore = errors.New("unknown ciphertext store")
+	// ErrAccessDenied is matched by *AccessDeniedError.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrKeyMismatch: the key path names a different tenant or store than
+	// the record stored under it.
+	ErrKeyMismatch = errors.New("key does not match record")
+
+	// ErrThrottled: KMS or SSM rejected the call because of rate limits.
+	ErrThrottled = errors.New("request throttled")
+	// ErrUnavailable: KMS or SSM failed transiently on its side.
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrKeyUnavailable: the KMS key is missing, disabled or pending deletion.
+	ErrKeyUnavailable = errors.New("kms key unavailable")
+	// ErrParameterNotFound: the SSM parameter (or Secrets Manager secret or
+	// version) holding the ciphertext is missing.
+	ErrParameterNotFound = errors.New("ssm parameter not found")
+
+	// ErrInvalidCiphertext: stored envelope material is malformed (bad
+	// base64, IV size, key size) or KMS rejected the wrapped DEK.
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+	// ErrAuthenticationFailed: AEAD authentication failed (wrong key, AAD or
+	// tampered ciphertext).
+	ErrAuthenticationFailed = errors.New("message authentication failed")
+	// ErrUnknownAlgorithm: SecretRecord.DEKAlg has no registered Cipher, or
+	// KEKAlg does not match the Client's key provider.
+	ErrUnknownAlgorithm = errors.New("unknown algorithm")
+)
+
+// Status errors returned by Client reads when SecretRecord.Status is not
+// StatusActive. Match them with errors.Is.
+var (
+	ErrSecretDeleted   = errors.New("secret is deleted")
+	ErrSecretSuspended = errors.New("secret is suspended")
+	ErrSecretRejected  = errors.New("secret is rejected")
+	ErrSecretDraft     = errors.New("secret is a draft")
+	ErrSecretClosed    = errors.New("secret is closed")
+	ErrSecretInactive  = errors.New("secret is not active")
+)
+
+// Error is the error type returned across the SDK. Op names the failing
+// operation ("Client.GetSecret", "kms.Decrypt", "repo.GetSecret", ...); Key
+// and Store are filled in when known. Err wraps the cause, which usually
+// includes one of the sentinels above.
+type Error struct {
+	Op    string
+	Key   string
+	Store Store
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("vault: ")
+	b.WriteString(e.Op)
+	if e.Key != "" {
+		b.WriteString(" " + strconv.Quote(e.Key))
+	}
+	if e.Store != "" {
+		b.WriteString(" [" + string(e.Store) + "]")
+	}
+	if e.Err != nil {
+		b.WriteString(": " + e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// withKey fills in Key and Store on a top-level *Error that lacks them (as
+// returned by providers, which do not know the secret key). Other errors are
+// wrapped in a new *Error for op.
+func withKey(err error, op, key string, store Store) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		if e.Key != "" {
+			return e
+		}
+		cp := *e
+		cp.Key = key
+		if cp.Store == "" {
+			cp.Store = store
+		}
+		return &cp
+	}
+	return &Error{Op: op, Key: key, Store: store, Err: err}
+}
+
+// errorf builds a *Error whose cause wraps sentinel with a formatted detail.
+func errorf(op, key string, sentinel error, format string, args ...any) *Error {
+	return &Error{Op: op, Key: key, Err: fmt.Errorf("%w: %s", sentinel, fmt.Sprintf(format, args...))}
+}
+
+// awsError wraps an AWS SDK error for op, adding the matching sentinel
+// based on the API error code. The original error stays reachable with
+// errors.As (e.g. *types.ParameterNotFound, smithy.APIError).
+func awsError(op, key string, store Store, err error) *Error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if s := awsSentinel(apiErr.ErrorCode()); s != nil {
+			err = fmt.Errorf("%w: %w", s, err)
+		}
+	}
+	return &Error{Op: op, Key: key, Store: store, Err: err}
+}
+
+func awsSentinel(code string) error {
+	switch code {
+	case "ThrottlingException", "Throttling", "TooManyRequestsException",
+		"RequestLimitExceeded", "LimitExceededException", "TooManyUpdates":
+		return ErrThrottled
+	case "KMSInternalException", "DependencyTimeoutException", "InternalServerError",
+		"InternalFailure", "InternalServiceError", "ServiceUnavailable", "ServiceUnavailableException":
+		return ErrUnavailable
+	case "NotFoundException", "DisabledException", "KMSInvalidStateException",
+		"KeyUnavailableException":
+		return ErrKeyUnavailable
+	case "InvalidCiphertextException", "IncorrectKeyException":
+		return ErrInvalidCiphertext
+	case "ParameterNotFound", "ParameterVersionNotFound", "ResourceNotFoundException":
+		return ErrParameterNotFound
+	case "ParameterAlreadyExists":
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// statusErr maps a record status to its read error. StatusActive maps to nil;
+// StatusDraft does too when allowDraft is set. Unknown or empty statuses are
+// rejected with ErrSecretInactive.
+func statusErr(s Status, allowDraft bool) error {
+	switch s {
+	case StatusActive:
+		return nil
+	case StatusDraft:
+		if allowDraft {
+			return nil
+		}
+		return ErrSecretDraft
+	case StatusDeleted:
+		return ErrSecretDeleted
+	case StatusSuspended:
+		return ErrSecretSuspended
+	case StatusRejected:
+		return ErrSecretRejected
+	case StatusClosed:
+		return ErrSecretClosed
+	default:
+		return ErrSecretInactive
+	}
+}
@@ -0,0 +1,128 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestErrors_NotFound(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	_ = fakes.NewDB(t, dsn)
+	pg, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+	require.NoError(t, err)
+
+	for name, repo := range map[string]vault.SecretReader{
+		"memory":   vault.NewInMemoryRepo(),
+		"postgres": pg,
+		"stub":     &stubRepo{},
+	} {
+		client := vault.NewClient(repo,
+			vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute),
+			vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute),
+			time.Minute)
+		_, err := client.GetSecret(ctx, "/ds/vault/missing")
+		require.ErrorIs(t, err, vault.ErrNotFound, name)
+
+		var verr *vault.Error
+		require.True(t, errors.As(err, &verr), name)
+		require.Equal(t, "/ds/vault/missing", verr.Key, name)
+	}
+}
+
+func TestErrors_Upstream(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	kmsFake := &fakes.KMS{}
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	repo := vault.NewInMemoryRepo()
+	ssmProv := vault.NewSSMProvider(ssmFake, 16, time.Minute)
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), ssmProv, time.Minute, openACL)
+
+	rec, err := client.PutSecret(ctx, key, []byte("secret"), vault.PutOptions{
+		TenantID: tenantID,
+		Store:    vault.StoreAWSSSM,
+		KEKKeyID: "alias/ds-vault",
+	})
+	require.NoError(t, err)
+
+	_, err = client.PutSecret(ctx, key, []byte("again"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.ErrorIs(t, err, vault.ErrAlreadyExists)
+
+	// SSM parameter missing.
+	delete(ssmFake.Values, key)
+	ssmProv.Invalidate(key)
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrParameterNotFound)
+	var verr *vault.Error
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, key, verr.Key)
+	require.Equal(t, vault.StoreAWSSSM, verr.Store)
+
+	// KMS throttling, with the AWS error still reachable.
+	kmsFake.Err = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "slow down"}
+	other := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	require.NoError(t, repo.CreateSecret(ctx, &vault.SecretRecord{
+		ID: keySecretID(t, other), Key: other, TenantID: tenantID, Store: vault.StoreDSVault, Status: vault.StatusActive,
+		WrappedDEK: rec.WrappedDEK, KEKKeyID: "alias/ds-vault",
+	}))
+	_, err = client.GetSecret(ctx, other)
+	require.ErrorIs(t, err, vault.ErrThrottled)
+	var apiErr smithy.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, "ThrottlingException", apiErr.ErrorCode())
+}
+
+func TestErrors_AuthenticationFailed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo,
+		vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute),
+		time.Minute, openACL)
+
+	rec, err := client.PutSecret(ctx, key, []byte("secret"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+
+	// Move the row to another tenant: the key path no longer names it.
+	tampered := *rec
+	tampered.TenantID = uuid.New()
+	repo.Put(&tampered)
+
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrKeyMismatch)
+
+	// Move the row to another key of the same tenant: the AAD no longer matches.
+	moved := *rec
+	moved.Key = vault.MakeKey(rec.ID, tenantID, string(vault.StoreDSVault), string(vault.EnvProd), "ds", "vault")
+	repo.Put(&moved)
+
+	_, err = client.GetSecret(ctx, moved.Key)
+	require.ErrorIs(t, err, vault.ErrAuthenticationFailed)
+
+	// A key naming another secret id does not reach the row at all.
+	moved.Key = vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	repo.Put(&moved)
+
+	_, err = client.GetSecret(ctx, moved.Key)
+	require.ErrorIs(t, err, vault.ErrKeyMismatch)
+}
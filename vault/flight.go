@@ -0,0 +1,115 @@
+package vault
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls for the same key: while a call for
+// key is in flight, later callers wait for its result instead of starting
+// their own. Internal helper; not exported.
+//
+// The shared call runs on context.WithoutCancel of the first caller's
+// context, so it keeps that caller's values (principal, tracing) but is not
+// cancelled when any one caller goes away. Each caller stops waiting when its
+// own ctx is done and gets ctx.Err(); the call itself runs to completion and
+// its result is still delivered to the remaining waiters.
+//
+// The zero value is ready for use.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done    chan struct{}
+	v       T
+	err     error
+	refs    int     // the running call plus its waiting callers; guarded by mu
+	release func(T) // called on v after the last reference is dropped
+}
+
+// Do runs fn once per in-flight key and returns its result to every caller
+// that asked for key meanwhile.
+func (g *flightGroup[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	var v T
+	err := g.do(ctx, key, fn, func(r T) { v = r }, nil)
+	return v, err
+}
+
+// do is Do handing a successful result to use instead of returning it. If
+// release is set, it is called on the result once the call has finished and
+// every caller that waited for it has returned from use (or given up), so
+// use may read the result but must not keep it.
+func (g *flightGroup[T]) do(ctx context.Context, key string, fn func(ctx context.Context) (T, error), use, release func(T)) error {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall[T]{done: make(chan struct{}), refs: 1, release: release}
+		g.calls[key] = call
+		go g.run(context.WithoutCancel(ctx), key, call, fn)
+	}
+	call.refs++
+	g.mu.Unlock()
+	defer g.unref(call)
+
+	select {
+	case <-call.done:
+		if call.err == nil {
+			use(call.v)
+		}
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *flightGroup[T]) run(ctx context.Context, key string, call *flightCall[T], fn func(ctx context.Context) (T, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+		g.unref(call)
+	}()
+	call.v, call.err = fn(ctx)
+}
+
+// unref drops a reference to call and releases its result after the last.
+func (g *flightGroup[T]) unref(call *flightCall[T]) {
+	g.mu.Lock()
+	call.refs--
+	last := call.refs == 0
+	g.mu.Unlock()
+	if last && call.err == nil && call.release != nil {
+		call.release(call.v)
+	}
+}
+
+// secretFlight is a flightGroup for secret material (plaintexts and DEKs).
+// The shared result is held in a SecureBytes; each caller gets its own copy,
+// and the SecureBytes is destroyed once the last waiting caller has copied
+// it, so no shared buffer outlives the flight. The zero value is ready for
+// use; set lock to keep the shared copy in mlock'ed memory.
+type secretFlight struct {
+	g    flightGroup[*SecureBytes]
+	lock bool
+}
+
+// Do runs fn once per in-flight key, like flightGroup.Do. The slice fn
+// returns is wiped once it has been copied; callers own what Do returns.
+func (f *secretFlight) Do(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	err := f.g.do(ctx, key, func(ctx context.Context) (*SecureBytes, error) {
+		b, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(b)
+		return newSecureBytes(b, f.lock), nil
+	}, func(s *SecureBytes) { out, _ = s.clone() }, (*SecureBytes).Destroy)
+	return out, err
+}
@@ -0,0 +1,204 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// AES-GCM-SIV (RFC 8452) is nonce-misuse resistant: repeating a nonce only
+// reveals whether the same plaintext was sealed twice. Neither the standard
+// library nor x/crypto implements it.
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+	gcmSIVMaxInput  = 1 << 36 // bytes, for both plaintext and AAD
+)
+
+var errGCMSIVOpen = errors.New("gcmsiv: message authentication failed")
+
+// gcmSIV implements cipher.AEAD for AEAD_AES_256_GCM_SIV. Per-message keys
+// are derived from the key-generating key and the nonce on every call.
+type gcmSIV struct {
+	kgk cipher.Block
+}
+
+// newGCMSIV returns AES-256-GCM-SIV keyed with the 32-byte key.
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("gcmsiv: key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSIV{kgk: block}, nil
+}
+
+func (*gcmSIV) NonceSize() int { return gcmSIVNonceSize }
+func (*gcmSIV) Overhead() int  { return gcmSIVTagSize }
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("gcmsiv: incorrect nonce length")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxInput || uint64(len(additionalData)) > gcmSIVMaxInput {
+		panic("gcmsiv: message too large")
+	}
+	authKey, enc := g.deriveKeys(nonce)
+	tag := gcmSIVTag(authKey, enc, nonce, plaintext, additionalData)
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	gcmSIVCTR(enc, tag, out[:len(plaintext)], plaintext)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("gcmsiv: incorrect nonce length")
+	}
+	if len(ciphertext) < gcmSIVTagSize ||
+		uint64(len(ciphertext)) > gcmSIVMaxInput+gcmSIVTagSize || uint64(len(additionalData)) > gcmSIVMaxInput {
+		return nil, errGCMSIVOpen
+	}
+	n := len(ciphertext) - gcmSIVTagSize
+	var tag [16]byte
+	copy(tag[:], ciphertext[n:])
+
+	authKey, enc := g.deriveKeys(nonce)
+	ret, out := sliceForAppend(dst, n)
+	gcmSIVCTR(enc, tag, out, ciphertext[:n])
+	want := gcmSIVTag(authKey, enc, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(tag[:], want[:]) != 1 {
+		clear(out)
+		return nil, errGCMSIVOpen
+	}
+	return ret, nil
+}
+
+// deriveKeys derives the message-authentication key and the
+// message-encryption block cipher for nonce (RFC 8452, section 4).
+func (g *gcmSIV) deriveKeys(nonce []byte) ([16]byte, cipher.Block) {
+	var in, out [16]byte
+	var derived [48]byte // 16 bytes of authentication key, 32 of encryption key
+	copy(in[4:], nonce)
+	for i := range uint32(6) {
+		binary.LittleEndian.PutUint32(in[:4], i)
+		g.kgk.Encrypt(out[:], in[:])
+		copy(derived[8*i:], out[:8])
+	}
+	var authKey [16]byte
+	copy(authKey[:], derived[:16])
+	enc, err := aes.NewCipher(derived[16:])
+	if err != nil {
+		panic(err) // 32-byte key
+	}
+	clear(derived[:])
+	return authKey, enc
+}
+
+// gcmSIVTag computes the tag over additionalData and plaintext.
+func gcmSIVTag(authKey [16]byte, enc cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+	p.update(lengths[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+	var tag [16]byte
+	enc.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// gcmSIVCTR XORs src with the AES-CTR keystream that starts at tag, with the
+// top bit set, and increments the first 32 bits little-endian.
+func gcmSIVCTR(enc cipher.Block, tag [16]byte, dst, src []byte) {
+	ctr := tag
+	ctr[15] |= 0x80
+	var ks [16]byte
+	for len(src) > 0 {
+		enc.Encrypt(ks[:], ctr[:])
+		binary.LittleEndian.PutUint32(ctr[:4], binary.LittleEndian.Uint32(ctr[:4])+1)
+		n := subtle.XORBytes(dst, src, ks[:])
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// polyval is POLYVAL (RFC 8452, section 3) over GF(2^128) modulo
+// x^128 + x^127 + x^126 + x^121 + 1. Elements are little-endian: bit i of
+// (lo, hi) is the coefficient of x^i (x^(64+i) for hi).
+type polyval struct {
+	hLo, hHi uint64
+	sLo, sHi uint64
+}
+
+func newPolyval(h [16]byte) *polyval {
+	return &polyval{hLo: binary.LittleEndian.Uint64(h[:8]), hHi: binary.LittleEndian.Uint64(h[8:])}
+}
+
+// update absorbs data, zero-padded to a multiple of 16 bytes.
+func (p *polyval) update(data []byte) {
+	var block [16]byte
+	for len(data) > 0 {
+		n := copy(block[:], data)
+		clear(block[n:])
+		data = data[n:]
+		p.sLo ^= binary.LittleEndian.Uint64(block[:8])
+		p.sHi ^= binary.LittleEndian.Uint64(block[8:])
+		p.sLo, p.sHi = polyvalDot(p.sLo, p.sHi, p.hLo, p.hHi)
+	}
+}
+
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+	binary.LittleEndian.PutUint64(out[:8], p.sLo)
+	binary.LittleEndian.PutUint64(out[8:], p.sHi)
+	return out
+}
+
+// polyvalDot returns a*b*x^-128. It walks a's bits from x^0 up, adding b
+// and dividing by x at each step, so a_i*b ends up multiplied by
+// x^-(128-i). Branch-free on the data.
+func polyvalDot(aLo, aHi, bLo, bHi uint64) (uint64, uint64) {
+	var rLo, rHi uint64
+	for i := range 128 {
+		var bit uint64
+		if i < 64 {
+			bit = aLo >> i & 1
+		} else {
+			bit = aHi >> (i - 64) & 1
+		}
+		m := -bit
+		rLo ^= bLo & m
+		rHi ^= bHi & m
+		// r * x^-1: if r has a constant term, add the modulus first. The
+		// modulus shifted right is x^127 + x^126 + x^125 + x^120.
+		m = -(rLo & 1)
+		rLo = rLo>>1 | rHi<<63
+		rHi = rHi>>1 ^ 0xe100000000000000&m
+	}
+	return rLo, rHi
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the
+// extension.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
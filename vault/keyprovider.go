@@ -0,0 +1,36 @@
+package vault
+
+import "context"
+
+// KeyUnwrapper turns a wrapped DEK (SecretRecord.WrappedDEK) back into the
+// plaintext DEK. encCtx is the EncryptionContext from MakeAADAndEncCtx and
+// must match what the DEK was wrapped with; keyID is SecretRecord.KEKKeyID.
+// Client depends on this interface for reads. Implementations:
+// *KMSProvider (AWS KMS) and *LocalKeyring (AES key wrap, no AWS).
+// The returned DEK belongs to the caller, which wipes it after use; never
+// return a slice that is shared with a cache or other callers.
+type KeyUnwrapper interface {
+	DecryptDEK(ctx context.Context, wrappedB64 string, encCtx map[string]string, keyID string) ([]byte, error)
+}
+
+// KeyProvider is a KeyUnwrapper that can also mint new DEKs. Client.PutSecret
+// and Client.RotateSecret require it.
+type KeyProvider interface {
+	KeyUnwrapper
+	// GenerateDEK returns a fresh 32-byte DEK and Base64(wrapped DEK) under
+	// the KEK keyID, bound to encCtx.
+	GenerateDEK(ctx context.Context, keyID string, encCtx map[string]string) ([]byte, string, error)
+	// KEKAlg is recorded in SecretRecord.KEKAlg for DEKs it wraps.
+	KEKAlg() string
+}
+
+// KeyRewrapper moves a wrapped DEK from one KEK to another without exposing
+// it to the caller. Rewrapper requires it.
+type KeyRewrapper interface {
+	RewrapDEK(ctx context.Context, wrappedB64 string, encCtx map[string]string, srcKeyID, dstKeyID string) (string, error)
+}
+
+// dekInvalidator is implemented by key providers that cache unwrapped DEKs.
+type dekInvalidator interface {
+	InvalidateDEK(wrappedB64 string, encCtx map[string]string, keyID string)
+}
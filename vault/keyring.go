@@ -0,0 +1,224 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// KEKAlgAESKW is recorded in SecretRecord.KEKAlg for DEKs wrapped by a
+// LocalKeyring.
+const KEKAlgAESKW = "AES256-KW"
+
+// LocalKeyring is a KeyProvider/KeyRewrapper backed by local 256-bit KEKs,
+// for on-prem and offline deployments without AWS KMS.
+//
+// DEKs are wrapped with AES key wrap (RFC 3394). KMS binds the
+// EncryptionContext to the wrapped DEK; AES-KW has no associated data, so
+// the wrapping key is derived per context instead:
+//
+//	wrapKey = HMAC-SHA256(KEK, "ds-vault/aes-kw|" + canonical JSON(encCtx))
+//
+// A wrapped DEK therefore only unwraps with the same KEK and the exact
+// EncryptionContext it was wrapped under, as with KMS.
+//
+// LocalKeyring is immutable after construction and safe for concurrent use.
+type LocalKeyring struct {
+	keys map[string][]byte
+}
+
+// NewLocalKeyring builds a keyring from key id -> 32-byte KEK. The map is
+// copied.
+func NewLocalKeyring(keys map[string][]byte) (*LocalKeyring, error) {
+	if len(keys) == 0 {
+		return nil, errorf("keyring.Open", "", ErrInvalidArgument, "at least one key is required")
+	}
+	kr := &LocalKeyring{keys: make(map[string][]byte, len(keys))}
+	for id, k := range keys {
+		if id == "" {
+			return nil, errorf("keyring.Open", "", ErrInvalidArgument, "empty key id")
+		}
+		if len(k) != 32 {
+			return nil, errorf("keyring.Open", "", ErrInvalidArgument, "key %q: want 32 bytes, got %d", id, len(k))
+		}
+		kr.keys[id] = append([]byte(nil), k...)
+	}
+	return kr, nil
+}
+
+// keyringFile is the on-disk format read by LoadLocalKeyring:
+//
+//	{"keys": {"local-2025": "<base64 32 bytes>", ...}}
+type keyringFile struct {
+	Keys map[string]string `json:"keys"`
+}
+
+// LoadLocalKeyring reads a JSON keyring file (see keyringFile). Protect the
+// file like any other key material.
+func LoadLocalKeyring(path string) (*LocalKeyring, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &Error{Op: "keyring.Open", Err: err}
+	}
+	var f keyringFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, errorf("keyring.Open", "", ErrInvalidArgument, "%s: %v", path, err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, b64 := range f.Keys {
+		k, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, errorf("keyring.Open", "", ErrInvalidArgument, "key %q base64: %v", id, err)
+		}
+		keys[id] = k
+	}
+	return NewLocalKeyring(keys)
+}
+
+// KEKAlg reports KEKAlgAESKW.
+func (k *LocalKeyring) KEKAlg() string { return KEKAlgAESKW }
+
+// GenerateDEK returns a random 32-byte DEK wrapped under keyID.
+func (k *LocalKeyring) GenerateDEK(ctx context.Context, keyID string, encCtx map[string]string) ([]byte, string, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, "", errorf("keyring.Wrap", "", ErrKeyUnavailable, "unknown key id %q", keyID)
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, "", &Error{Op: "keyring.Wrap", Err: err}
+	}
+	wrapped, err := aesKeyWrap(wrapKey(kek, encCtx), dek)
+	if err != nil {
+		return nil, "", &Error{Op: "keyring.Wrap", Err: err}
+	}
+	return dek, base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// DecryptDEK unwraps wrappedB64 under keyID. When keyID is empty every key
+// is tried in id order; the AES-KW integrity check identifies the right one.
+func (k *LocalKeyring) DecryptDEK(ctx context.Context, wrappedB64 string, encCtx map[string]string, keyID string) ([]byte, error) {
+	const op = "keyring.Unwrap"
+	blob, err := base64.StdEncoding.DecodeString(wrappedB64)
+	if err != nil {
+		return nil, errorf(op, "", ErrInvalidCiphertext, "wrapped_dek base64: %v", err)
+	}
+	ids := []string{keyID}
+	if keyID == "" {
+		ids = k.keyIDs()
+	} else if _, ok := k.keys[keyID]; !ok {
+		return nil, errorf(op, "", ErrKeyUnavailable, "unknown key id %q", keyID)
+	}
+	for _, id := range ids {
+		if dek, err := aesKeyUnwrap(wrapKey(k.keys[id], encCtx), blob); err == nil {
+			return dek, nil
+		}
+	}
+	return nil, errorf(op, "", ErrInvalidCiphertext, "wrapped dek does not unwrap under key %q with this encryption context", keyID)
+}
+
+// RewrapDEK unwraps under srcKeyID and wraps the same DEK under dstKeyID.
+func (k *LocalKeyring) RewrapDEK(ctx context.Context, wrappedB64 string, encCtx map[string]string, srcKeyID, dstKeyID string) (string, error) {
+	dst, ok := k.keys[dstKeyID]
+	if !ok {
+		return "", errorf("keyring.Rewrap", "", ErrKeyUnavailable, "unknown key id %q", dstKeyID)
+	}
+	dek, err := k.DecryptDEK(ctx, wrappedB64, encCtx, srcKeyID)
+	if err != nil {
+		return "", err
+	}
+	defer clear(dek)
+	wrapped, err := aesKeyWrap(wrapKey(dst, encCtx), dek)
+	if err != nil {
+		return "", &Error{Op: "keyring.Rewrap", Err: err}
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+func (k *LocalKeyring) keyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func wrapKey(kek []byte, encCtx map[string]string) []byte {
+	m := hmac.New(sha256.New, kek)
+	m.Write([]byte("ds-vault/aes-kw|"))
+	m.Write([]byte(encCtxJSON(encCtx)))
+	return m.Sum(nil)
+}
+
+// aesKWIV is the RFC 3394 default initial value.
+var aesKWIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKeyWrap implements RFC 3394 section 2.2.1.
+func aesKeyWrap(kek, pt []byte) ([]byte, error) {
+	if len(pt) < 16 || len(pt)%8 != 0 {
+		return nil, fmt.Errorf("aes-kw: plaintext must be a multiple of 8 bytes and at least 16")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(pt) / 8
+	out := make([]byte, 8+len(pt))
+	copy(out, aesKWIV)
+	copy(out[8:], pt)
+	var buf [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf[:8], out[:8])
+			copy(buf[8:], out[8*i:8*i+8])
+			block.Encrypt(buf[:], buf[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[8*i:], buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap implements RFC 3394 section 2.2.2 including the integrity
+// check.
+func aesKeyUnwrap(kek, ct []byte) ([]byte, error) {
+	if len(ct) < 24 || len(ct)%8 != 0 {
+		return nil, fmt.Errorf("aes-kw: bad wrapped length %d", len(ct))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(ct)/8 - 1
+	a := make([]byte, 8)
+	copy(a, ct[:8])
+	r := make([]byte, len(ct)-8)
+	copy(r, ct[8:])
+	var buf [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[8*(i-1):8*i])
+			block.Decrypt(buf[:], buf[:])
+			copy(a, buf[:8])
+			copy(r[8*(i-1):], buf[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a, aesKWIV) != 1 {
+		clear(r)
+		return nil, fmt.Errorf("aes-kw: integrity check failed")
+	}
+	return r, nil
+}
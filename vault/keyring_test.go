@@ -0,0 +1,89 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func newKEK(t *testing.T) []byte {
+	t.Helper()
+	k := make([]byte, 32)
+	_, err := rand.Read(k)
+	require.NoError(t, err)
+	return k
+}
+
+func TestLocalKeyring_ClientRoundTrip(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	body := `{"keys": {"edge-1": "` + base64.StdEncoding.EncodeToString(newKEK(t)) + `"}}`
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	kr, err := vault.LoadLocalKeyring(path)
+	require.NoError(t, err)
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvProd), "ds", "vault")
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, kr, vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute), time.Minute, openACL)
+
+	rec, err := client.PutSecret(ctx, key, []byte("offline"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "edge-1"})
+	require.NoError(t, err)
+	require.Equal(t, vault.KEKAlgAESKW, rec.KEKAlg)
+
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("offline"), pt)
+
+	// The wrapped DEK is bound to the EncryptionContext.
+	_, encCtx := vault.MakeAADAndEncCtx(uuid.New(), key)
+	_, err = kr.DecryptDEK(ctx, rec.WrappedDEK, encCtx, "edge-1")
+	require.ErrorIs(t, err, vault.ErrInvalidCiphertext)
+
+	_, err = kr.DecryptDEK(ctx, rec.WrappedDEK, encCtx, "missing")
+	require.ErrorIs(t, err, vault.ErrKeyUnavailable)
+}
+
+func TestLocalKeyring_Rewrap(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	kr, err := vault.NewLocalKeyring(map[string][]byte{"old": newKEK(t), "new": newKEK(t)})
+	require.NoError(t, err)
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	repo := vault.NewInMemoryRepo()
+	ssm := vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute)
+	client := vault.NewClient(repo, kr, ssm, time.Minute)
+	_, err = client.PutSecret(ctx, key, []byte("moved"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "old"})
+	require.NoError(t, err)
+
+	rep, err := vault.NewRewrapper(repo, kr).Run(ctx, vault.RewrapOptions{DestinationKeyID: "new"})
+	require.NoError(t, err)
+	require.Equal(t, 1, rep.Rewrapped)
+
+	rec, err := repo.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "new", rec.KEKKeyID)
+
+	// A fresh client (cold cache) reads through the new KEK.
+	pt, err := vault.NewClient(repo, kr, ssm, time.Minute, openACL).GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("moved"), pt)
+
+	_, err = vault.NewLocalKeyring(map[string][]byte{"short": make([]byte, 16)})
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+}
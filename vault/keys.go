@@ -2,10 +2,15 @@ package vault
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+// validSegment matches the domain and service segments of a key.
+var validSegment = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
 // Canonical: /<domain>/<service>/<store>/<secret_id>/<tenant_id>/<environment>
 func MakeKey(secretID, tenantID uuid.UUID, store, env string, domain, service string) string {
 	if domain == "" {
@@ -16,3 +21,135 @@ func MakeKey(secretID, tenantID uuid.UUID, store, env string, domain, service st
 	}
 	return fmt.Sprintf("/%s/%s/%s/%s/%s/%s", domain, service, store, secretID, tenantID, env)
 }
+
+// SecretKey is the parsed form of a canonical key path, as built by
+// MakeKey.
+type SecretKey struct {
+	Domain      string
+	Service     string
+	Store       Store
+	SecretID    uuid.UUID
+	TenantID    uuid.UUID
+	Environment Environment
+}
+
+// ParseKey parses a canonical key path and validates it (see
+// SecretKey.Validate). The UUIDs must be in the lowercase, dashed form
+// MakeKey writes. Malformed keys fail with ErrInvalidArgument.
+func ParseKey(key string) (SecretKey, error) {
+	k, err := parseKey(key, true)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	if err := k.Validate(); err != nil {
+		return SecretKey{}, withKey(err, "ParseKey", key, "")
+	}
+	return k, nil
+}
+
+// parseKey splits key into its segments without validating the store, so
+// the Client can accept stores registered with WithCiphertextStore. Unless
+// canonical is set, the UUIDs may be in any form uuid.Parse accepts.
+func parseKey(key string, canonical bool) (SecretKey, error) {
+	const op = "ParseKey"
+	parts := strings.Split(key, "/")
+	if len(parts) != 7 || parts[0] != "" {
+		return SecretKey{}, errorf(op, key, ErrInvalidArgument,
+			"want /<domain>/<service>/<store>/<secret_id>/<tenant_id>/<environment>")
+	}
+	parseUUID := uuid.Parse
+	if canonical {
+		parseUUID = parseCanonicalUUID
+	}
+	secretID, err := parseUUID(parts[4])
+	if err != nil {
+		return SecretKey{}, errorf(op, key, ErrInvalidArgument, "secret id: %v", err)
+	}
+	tenantID, err := parseUUID(parts[5])
+	if err != nil {
+		return SecretKey{}, errorf(op, key, ErrInvalidArgument, "tenant id: %v", err)
+	}
+	k := SecretKey{
+		Domain:      parts[1],
+		Service:     parts[2],
+		Store:       Store(parts[3]),
+		SecretID:    secretID,
+		TenantID:    tenantID,
+		Environment: Environment(parts[6]),
+	}
+	if err := k.validate(func(s Store) bool { return validSegment.MatchString(string(s)) }); err != nil {
+		return SecretKey{}, withKey(err, op, key, "")
+	}
+	return k, nil
+}
+
+// parseCanonicalUUID parses s as a lowercase, dashed UUID. uuid.Parse also
+// accepts uppercase, undashed, urn:uuid: and {...} forms, which would give a
+// secret several keys that all bind the same AAD.
+func parseCanonicalUUID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err == nil && id.String() != s {
+		err = fmt.Errorf("not canonical, want %s", id)
+	}
+	return id, err
+}
+
+// String returns the canonical key path, as MakeKey does.
+func (k SecretKey) String() string {
+	return MakeKey(k.SecretID, k.TenantID, string(k.Store), string(k.Environment), k.Domain, k.Service)
+}
+
+// Validate checks that Domain and Service are non-empty lowercase
+// [a-z0-9_-] segments, that SecretID and TenantID are set, and that Store
+// and Environment are known values. It fails with ErrInvalidArgument.
+func (k SecretKey) Validate() error {
+	return k.validate(func(s Store) bool {
+		switch s {
+		case StoreDSVault, StoreAWSSSM, StoreAWSSecretsManager:
+			return true
+		}
+		return false
+	})
+}
+
+func (k SecretKey) validate(knownStore func(Store) bool) error {
+	const op = "SecretKey.Validate"
+	switch {
+	case !validSegment.MatchString(k.Domain):
+		return errorf(op, "", ErrInvalidArgument, "invalid domain %q", k.Domain)
+	case !validSegment.MatchString(k.Service):
+		return errorf(op, "", ErrInvalidArgument, "invalid service %q", k.Service)
+	case !knownStore(k.Store):
+		return errorf(op, "", ErrInvalidArgument, "unknown store %q", k.Store)
+	case k.SecretID == uuid.Nil:
+		return errorf(op, "", ErrInvalidArgument, "secret id is required")
+	case k.TenantID == uuid.Nil:
+		return errorf(op, "", ErrInvalidArgument, "tenant id is required")
+	case k.Environment != EnvDev && k.Environment != EnvProd:
+		return errorf(op, "", ErrInvalidArgument, "unknown environment %q", k.Environment)
+	}
+	return nil
+}
+
+// checkKey verifies that key names rec's secret, tenant and store, so a
+// caller cannot reach another tenant's record through a key path it was
+// handed. Stores registered with WithCiphertextStore are accepted. It runs on
+// reads, so UUIDs in non-canonical forms are accepted for records written
+// under such keys before writes required the canonical form.
+func (c *Client) checkKey(key string, rec *SecretRecord) error {
+	const op = "Client.checkKey"
+	k, err := parseKey(key, false)
+	if err != nil {
+		return err
+	}
+	if k.SecretID != rec.ID {
+		return errorf(op, key, ErrKeyMismatch, "key names secret %s, record is %s", k.SecretID, rec.ID)
+	}
+	if k.TenantID != rec.TenantID {
+		return errorf(op, key, ErrKeyMismatch, "key names tenant %s, record belongs to %s", k.TenantID, rec.TenantID)
+	}
+	if k.Store != rec.Store {
+		return errorf(op, key, ErrKeyMismatch, "key names store %s, record is in %s", k.Store, rec.Store)
+	}
+	return nil
+}
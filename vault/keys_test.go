@@ -0,0 +1,128 @@
+package vault_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// keySecretID returns the secret id key names, for records built by hand.
+func keySecretID(t *testing.T, key string) uuid.UUID {
+	t.Helper()
+	k, err := vault.ParseKey(key)
+	require.NoError(t, err)
+	return k.SecretID
+}
+
+func TestParseKey(t *testing.T) {
+	t.Parallel()
+	secretID, tenantID := uuid.New(), uuid.New()
+	key := vault.MakeKey(secretID, tenantID, string(vault.StoreAWSSSM), string(vault.EnvProd), "ds", "vault")
+
+	k, err := vault.ParseKey(key)
+	require.NoError(t, err)
+	require.Equal(t, vault.SecretKey{
+		Domain: "ds", Service: "vault", Store: vault.StoreAWSSSM,
+		SecretID: secretID, TenantID: tenantID, Environment: vault.EnvProd,
+	}, k)
+	require.Equal(t, key, k.String())
+
+	valid := strings.Split(key, "/")
+	for name, key := range map[string]string{
+		"empty":        "",
+		"relative":     strings.TrimPrefix(key, "/"),
+		"short":        "/ds/vault/missing",
+		"extra":        key + "/x",
+		"secret id":    strings.Replace(key, secretID.String(), "not-a-uuid", 1),
+		"tenant id":    strings.Replace(key, tenantID.String(), "not-a-uuid", 1),
+		"nil tenant":   strings.Replace(key, tenantID.String(), uuid.Nil.String(), 1),
+		"uppercase":    strings.Replace(key, secretID.String(), strings.ToUpper(secretID.String()), 1),
+		"undashed":     strings.Replace(key, tenantID.String(), strings.ReplaceAll(tenantID.String(), "-", ""), 1),
+		"urn":          strings.Replace(key, secretID.String(), "urn:uuid:"+secretID.String(), 1),
+		"braces":       strings.Replace(key, tenantID.String(), "{"+tenantID.String()+"}", 1),
+		"store":        strings.Replace(key, "/aws_ssm/", "/file_blob/", 1),
+		"environment":  strings.TrimSuffix(key, "/prod") + "/staging",
+		"domain chars": "/D$/" + strings.Join(valid[2:], "/"),
+		"empty domain": "//" + strings.Join(valid[2:], "/"),
+	} {
+		_, err := vault.ParseKey(key)
+		require.ErrorIs(t, err, vault.ErrInvalidArgument, name)
+	}
+
+	require.ErrorIs(t, vault.SecretKey{}.Validate(), vault.ErrInvalidArgument)
+}
+
+func TestClient_KeyMustMatchRecord(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	kmsFake := &fakes.KMS{}
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), nil, time.Minute)
+
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	rec, err := client.PutSecret(ctx, key, []byte("secret"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+
+	// The same record stored under paths that name another secret, tenant or store,
+	// or are not canonical at all.
+	for _, tc := range []struct {
+		key  string
+		want error
+	}{
+		{vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault"), vault.ErrKeyMismatch},
+		{vault.MakeKey(rec.ID, uuid.New(), string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault"), vault.ErrKeyMismatch},
+		{vault.MakeKey(rec.ID, tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault"), vault.ErrKeyMismatch},
+		{"/legacy/" + uuid.NewString(), vault.ErrInvalidArgument},
+	} {
+		forged := *rec
+		forged.Key = tc.key
+		repo.Put(&forged)
+
+		calls := kmsFake.Calls
+		_, err := client.GetSecret(ctx, tc.key)
+		require.ErrorIs(t, err, tc.want, tc.key)
+		require.Equal(t, calls, kmsFake.Calls, "rejected before any KMS call")
+	}
+}
+
+func TestClient_NonCanonicalKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID, secretID := uuid.New(), uuid.New()
+	dek := make([]byte, 32)
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{Plaintext: dek}, 16, time.Minute), nil, time.Minute, openACL)
+
+	// A record written before keys had to be canonical stays readable.
+	legacy := "/ds/vault/ds_vault/" + strings.ToUpper(secretID.String()) + "/{" + tenantID.String() + "}/dev"
+	aad, _ := vault.MakeAADAndEncCtx(tenantID, legacy)
+	iv, ct, tag, err := fakes.EncryptWithDEK(dek, []byte("old"), aad)
+	require.NoError(t, err)
+	repo.Put(&vault.SecretRecord{
+		ID: secretID, TenantID: tenantID, Key: legacy, Store: vault.StoreDSVault, Status: vault.StatusActive,
+		Value: ct, IV: iv, Tag: tag, WrappedDEK: "V1JBUFBFRA==",
+	})
+	pt, err := client.GetSecret(ctx, legacy)
+	require.NoError(t, err)
+	require.Equal(t, "old", string(pt))
+
+	// Writes need the canonical form, and an id matching the key.
+	_, err = client.PutSecret(ctx, legacy, []byte("new"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "k"})
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	_, err = client.PutSecret(ctx, key, []byte("new"), vault.PutOptions{TenantID: tenantID, ID: secretID, KEKKeyID: "k"})
+	require.ErrorIs(t, err, vault.ErrKeyMismatch)
+	rec, err := client.PutSecret(ctx, key, []byte("new"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "k"})
+	require.NoError(t, err)
+	require.Equal(t, keySecretID(t, key), rec.ID)
+}
@@ -10,21 +10,44 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 )
 
 type KMSAPI interface {
 	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
 }
 
+// KMSWriteAPI is a KMSAPI that can also generate and re-wrap data keys.
+// KMSProvider detects it by type assertion: GenerateDEK and RewrapDEK fail
+// with ErrNotSupported on a read-only client.
+type KMSWriteAPI interface {
+	KMSAPI
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	ReEncrypt(ctx context.Context, params *kms.ReEncryptInput, optFns ...func(*kms.Options)) (*kms.ReEncryptOutput, error)
+}
+
+// KMSProvider implements KeyProvider and KeyRewrapper on top of AWS KMS and
+// caches unwrapped DEKs in a TTL cache as SecureBytes, which are zeroed when
+// they leave the cache. Concurrent DecryptDEK misses for the same wrapped DEK
+// share a single KMS Decrypt call.
 type KMSProvider struct {
-	kms   KMSAPI
-	cache *TTLCache[[]byte]
+	kms     KMSAPI
+	cache   *TTLCache[*SecureBytes]
+	flights secretFlight
 }
 
 func NewKMSProvider(k KMSAPI, cacheSize int, ttl time.Duration) *KMSProvider {
-	return &KMSProvider{kms: k, cache: NewTTLCache[[]byte](cacheSize, ttl)}
+	return &KMSProvider{kms: k, cache: NewTTLCache[*SecureBytes](cacheSize, ttl)}
+}
+
+// Purge zeroes and drops every cached DEK.
+func (p *KMSProvider) Purge() {
+	p.cache.Clear()
 }
 
+// KEKAlg reports KEKAlgAWSKMS.
+func (p *KMSProvider) KEKAlg() string { return KEKAlgAWSKMS }
+
 func encCtxJSON(ctx map[string]string) string {
 	if ctx == nil {
 		return "{}"
@@ -52,14 +75,24 @@ func (p *KMSProvider) cacheKey(wrappedB64 string, encCtx map[string]string, keyI
 	return wrappedB64 + "|" + encCtxJSON(encCtx) + "|" + keyID
 }
 
+// InvalidateDEK drops the cached plaintext DEK for the given wrapped DEK,
+// EncryptionContext and key id, if any.
+func (p *KMSProvider) InvalidateDEK(wrappedB64 string, encCtx map[string]string, keyID string) {
+	p.cache.Delete(p.cacheKey(wrappedB64, encCtx, keyID))
+}
+
+// DecryptDEK unwraps wrappedB64 with KMS Decrypt, or serves it from the DEK
+// cache. The returned DEK is a fresh copy owned by the caller.
 func (p *KMSProvider) DecryptDEK(ctx context.Context, wrappedB64 string, encCtx map[string]string, keyID string) ([]byte, error) {
 	ck := p.cacheKey(wrappedB64, encCtx, keyID)
-	if dek, ok := p.cache.Get(ck); ok {
-		return dek, nil
+	if hit, ok := p.cache.Get(ck); ok {
+		if dek, ok := hit.clone(); ok {
+			return dek, nil
+		}
 	}
 	blob, err := base64.StdEncoding.DecodeString(wrappedB64)
 	if err != nil {
-		return nil, fmt.Errorf("wrapped_dek base64: %w", err)
+		return nil, errorf("kms.Decrypt", "", ErrInvalidCiphertext, "wrapped_dek base64: %v", err)
 	}
 	in := &kms.DecryptInput{
 		CiphertextBlob: blob,
@@ -73,10 +106,67 @@ func (p *KMSProvider) DecryptDEK(ctx context.Context, wrappedB64 string, encCtx
 	if keyID != "" {
 		in.KeyId = &keyID
 	}
-	out, err := p.kms.Decrypt(ctx, in)
+	// The cache gets its own copy; the flight wipes out.Plaintext once every
+	// waiter has copied it.
+	return p.flights.Do(ctx, ck, func(ctx context.Context) ([]byte, error) {
+		out, err := p.kms.Decrypt(ctx, in)
+		if err != nil {
+			return nil, awsError("kms.Decrypt", "", "", err)
+		}
+		p.cache.Set(ck, NewSecureBytes(out.Plaintext))
+		return out.Plaintext, nil
+	})
+}
+
+// GenerateDEK asks KMS for a fresh AES-256 data key under the KEK keyID,
+// bound to encCtx. It returns the plaintext DEK and Base64(wrapped DEK) as it
+// should be stored in SecretRecord.WrappedDEK. The plaintext is not cached.
+func (p *KMSProvider) GenerateDEK(ctx context.Context, keyID string, encCtx map[string]string) ([]byte, string, error) {
+	if keyID == "" {
+		return nil, "", errorf("kms.GenerateDataKey", "", ErrInvalidArgument, "key id is required")
+	}
+	kw, ok := p.kms.(KMSWriteAPI)
+	if !ok {
+		return nil, "", errorf("kms.GenerateDataKey", "", ErrNotSupported, "KMS client %T is read-only", p.kms)
+	}
+	out, err := kw.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             &keyID,
+		KeySpec:           types.DataKeySpecAes256,
+		EncryptionContext: encCtx,
+	})
+	if err != nil {
+		return nil, "", awsError("kms.GenerateDataKey", "", "", err)
+	}
+	return out.Plaintext, base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+// RewrapDEK re-wraps a wrapped DEK from srcKeyID to dstKeyID with KMS
+// ReEncrypt, keeping the same EncryptionContext on both sides. The plaintext
+// DEK never leaves KMS. It returns Base64(new wrapped DEK).
+func (p *KMSProvider) RewrapDEK(ctx context.Context, wrappedB64 string, encCtx map[string]string, srcKeyID, dstKeyID string) (string, error) {
+	if dstKeyID == "" {
+		return "", errorf("kms.ReEncrypt", "", ErrInvalidArgument, "destination key id is required")
+	}
+	kw, ok := p.kms.(KMSWriteAPI)
+	if !ok {
+		return "", errorf("kms.ReEncrypt", "", ErrNotSupported, "KMS client %T is read-only", p.kms)
+	}
+	blob, err := base64.StdEncoding.DecodeString(wrappedB64)
+	if err != nil {
+		return "", errorf("kms.ReEncrypt", "", ErrInvalidCiphertext, "wrapped_dek base64: %v", err)
+	}
+	in := &kms.ReEncryptInput{
+		CiphertextBlob:               blob,
+		SourceEncryptionContext:      encCtx,
+		DestinationKeyId:             &dstKeyID,
+		DestinationEncryptionContext: encCtx,
+	}
+	if srcKeyID != "" {
+		in.SourceKeyId = &srcKeyID
+	}
+	out, err := kw.ReEncrypt(ctx, in)
 	if err != nil {
-		return nil, fmt.Errorf("KMS Decrypt: %w", err)
+		return "", awsError("kms.ReEncrypt", "", "", err)
 	}
-	p.cache.Set(ck, out.Plaintext)
-	return out.Plaintext, nil
+	return base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
 }
@@ -0,0 +1,162 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultInvalidationChannel is the Postgres NOTIFY channel used when
+// ListenerOptions.Channel is empty.
+const DefaultInvalidationChannel = "ds_vault_invalidate"
+
+// KeyInvalidator drops any cached state for a key. *Client,
+// *PostgresSecretRepository, *SSMProvider and *SecretsManagerProvider
+// implement it.
+type KeyInvalidator interface {
+	Invalidate(key string)
+}
+
+// cachePurger drops all cached state. Targets implementing it are purged when
+// an InvalidationListener reconnects, since notifications sent while it was
+// disconnected are lost.
+type cachePurger interface {
+	Purge()
+}
+
+// ListenerOptions configures an InvalidationListener.
+type ListenerOptions struct {
+	// Channel is the NOTIFY channel; DefaultInvalidationChannel if empty.
+	Channel string
+	// Targets are invalidated for every notified key. A *Client is usually
+	// all that is needed: it also invalidates its repository, KMS and store
+	// caches.
+	Targets []KeyInvalidator
+	// OnError, if set, receives connection and LISTEN errors. The listener
+	// keeps retrying regardless.
+	OnError func(error)
+	// RetryDelay is the pause before reconnecting; one second if <= 0.
+	RetryDelay time.Duration
+}
+
+// InvalidationListener subscribes to a Postgres NOTIFY channel whose payload
+// is a secret key, and invalidates that key in its targets. Notifications
+// come from PostgresSecretRepository writes (see SetNotifyChannel) or from a
+// trigger installed with InvalidationTriggerSQL, which also catches writes
+// made outside the SDK.
+type InvalidationListener struct {
+	connString string
+	opts       ListenerOptions
+}
+
+// NewInvalidationListener builds a listener for the Postgres database at
+// connString (URL or keyword/value form). Call Run to start it.
+func NewInvalidationListener(connString string, opts ListenerOptions) (*InvalidationListener, error) {
+	if opts.Channel == "" {
+		opts.Channel = DefaultInvalidationChannel
+	}
+	if !validIdent.MatchString(opts.Channel) {
+		return nil, errorf("listener.New", "", ErrInvalidArgument, "invalid channel name: %s", opts.Channel)
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+	return &InvalidationListener{connString: connString, opts: opts}, nil
+}
+
+// Run listens until ctx is done, reconnecting after failures, and returns
+// ctx.Err(). On every reconnect it purges all targets that support it, so
+// that changes missed while disconnected do not stay cached.
+func (l *InvalidationListener) Run(ctx context.Context) error {
+	for connected := false; ; {
+		err := l.listen(ctx, func() {
+			if connected {
+				l.purge()
+			}
+			connected = true
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if l.opts.OnError != nil {
+			l.opts.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.opts.RetryDelay):
+		}
+	}
+}
+
+// listen holds one connection until it fails; onListen runs once LISTEN is
+// in effect.
+func (l *InvalidationListener) listen(ctx context.Context, onListen func()) error {
+	const op = "listener.Run"
+	conn, err := pgx.Connect(ctx, l.connString)
+	if err != nil {
+		return &Error{Op: op, Err: fmt.Errorf("%w: %w", ErrUnavailable, err)}
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.opts.Channel}.Sanitize()); err != nil {
+		return &Error{Op: op, Err: err}
+	}
+	onListen()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return &Error{Op: op, Err: fmt.Errorf("%w: %w", ErrUnavailable, err)}
+		}
+		if n.Payload == "" {
+			continue
+		}
+		for _, t := range l.opts.Targets {
+			t.Invalidate(n.Payload)
+		}
+	}
+}
+
+func (l *InvalidationListener) purge() {
+	for _, t := range l.opts.Targets {
+		if p, ok := t.(cachePurger); ok {
+			p.Purge()
+		}
+	}
+}
+
+// InvalidationTriggerSQL returns a migration that installs a row trigger on
+// table which sends the changed row's key to channel on every INSERT, UPDATE
+// and DELETE. Use it when rows are also written outside the SDK; otherwise
+// PostgresSecretRepository.SetNotifyChannel is enough. The statements are
+// idempotent.
+func InvalidationTriggerSQL(table, channel string) (string, error) {
+	if !validTable.MatchString(table) {
+		return "", errorf("InvalidationTriggerSQL", "", ErrInvalidArgument, "invalid table name: %s", table)
+	}
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	if !validIdent.MatchString(channel) {
+		return "", errorf("InvalidationTriggerSQL", "", ErrInvalidArgument, "invalid channel name: %s", channel)
+	}
+	fn := strings.ReplaceAll(table, ".", "_") + "_notify"
+	return fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    PERFORM pg_notify('%[3]s', OLD.key);
+  ELSE
+    PERFORM pg_notify('%[3]s', NEW.key);
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS %[4]s ON %[2]s;
+CREATE TRIGGER %[4]s AFTER INSERT OR UPDATE OR DELETE ON %[2]s
+  FOR EACH ROW EXECUTE FUNCTION %[1]s();
+`, fn, table, channel, strings.ReplaceAll(table, ".", "_")+"_notify_trg"), nil
+}
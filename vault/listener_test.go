@@ -0,0 +1,118 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestInvalidationTriggerSQL(t *testing.T) {
+	sql, err := vault.InvalidationTriggerSQL("vault.secret_records", "")
+	require.NoError(t, err)
+	require.Contains(t, sql, "pg_notify('"+vault.DefaultInvalidationChannel+"', NEW.key)")
+	require.Contains(t, sql, "pg_notify('"+vault.DefaultInvalidationChannel+"', OLD.key)")
+	require.Contains(t, sql, "AFTER INSERT OR UPDATE OR DELETE ON vault.secret_records")
+
+	_, err = vault.InvalidationTriggerSQL("secrets; DROP TABLE x", "")
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	_, err = vault.InvalidationTriggerSQL("secret_records", "bad'channel")
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	_, err = vault.NewInvalidationListener("postgres://localhost/x", vault.ListenerOptions{Channel: "bad-channel"})
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+}
+
+func TestClient_Invalidate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	aad, _ := vault.MakeAADAndEncCtx(tenantID, key)
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+	iv, ct, tag, err := fakes.EncryptWithDEK(dek, []byte("s1"), aad)
+	require.NoError(t, err)
+
+	kmsFake := &fakes.KMS{Plaintext: dek}
+	ssmFake := &fakes.SSM{Values: map[string]string{key: ct}}
+	repo := &stubRepo{rec: &vault.SecretRecord{
+		ID: keySecretID(t, key), TenantID: tenantID, Key: key, Store: vault.StoreAWSSSM, Status: vault.StatusActive,
+		IV: iv, Tag: tag, WrappedDEK: base64.StdEncoding.EncodeToString([]byte("W")),
+	}}
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute),
+		vault.NewSSMProvider(ssmFake, 16, time.Minute), time.Minute, openACL)
+
+	_, err = client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	_, err = client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, 1, repo.calls)
+	require.Equal(t, 1, kmsFake.Calls)
+	require.Equal(t, 1, ssmFake.Calls)
+
+	client.Invalidate(key)
+	_, err = client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, 2, repo.calls)
+	require.Equal(t, 2, kmsFake.Calls, "DEK cache invalidated")
+	require.Equal(t, 2, ssmFake.Calls, "SSM cache invalidated")
+
+	client.Purge()
+	_, err = client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, 3, repo.calls)
+	require.Equal(t, 3, kmsFake.Calls)
+	require.Equal(t, 3, ssmFake.Calls)
+}
+
+// recordingInvalidator collects the keys it is asked to invalidate.
+type recordingInvalidator chan string
+
+func (r recordingInvalidator) Invalidate(key string) { r <- key }
+
+// TestInvalidationListener_Postgres needs a real Postgres; set DSVAULT_PG_DSN
+// to run it.
+func TestInvalidationListener_Postgres(t *testing.T) {
+	dsn, table, _ := pgTable(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	channel := "ds_vault_test_" + uuid.NewString()[:8]
+	got := make(recordingInvalidator, 16)
+	l, err := vault.NewInvalidationListener(dsn, vault.ListenerOptions{Channel: channel, Targets: []vault.KeyInvalidator{got}})
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- l.Run(ctx) }()
+
+	repo, err := vault.NewGormSecretRepository(postgres.Open(dsn), table)
+	require.NoError(t, err)
+	require.NoError(t, repo.SetNotifyChannel(channel))
+
+	key := "/test/listener/" + uuid.NewString()
+	rec := &vault.SecretRecord{ID: uuid.New(), TenantID: uuid.New(), Key: key, Store: vault.StoreDSVault, Status: vault.StatusActive}
+	// LISTEN may not be in effect yet; keep writing until a notification arrives.
+	require.Eventually(t, func() bool {
+		rec.Version = uuid.NewString()
+		if err := repo.CreateSecret(ctx, rec); err != nil {
+			_ = repo.UpdateSecret(ctx, rec, vault.Precondition{})
+		}
+		select {
+		case k := <-got:
+			return k == key
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -2,29 +2,192 @@ package vault
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 )
 
 type InMemoryRepo struct {
-	mu   sync.RWMutex
-	data map[string]*SecretRecord // by composite key (Key)
+	mu       sync.RWMutex
+	data     map[string]*SecretRecord   // by composite key (Key)
+	versions map[string][]*SecretRecord // version history by Key, oldest first
 }
 
 func NewInMemoryRepo() *InMemoryRepo {
-	return &InMemoryRepo{data: make(map[string]*SecretRecord)}
+	return &InMemoryRepo{data: make(map[string]*SecretRecord), versions: make(map[string][]*SecretRecord)}
 }
 
 func (r *InMemoryRepo) Put(rec *SecretRecord) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.data[rec.Key] = rec
+	r.data[rec.Key] = rec.clone()
+	r.writeVersion(rec)
+}
+
+// writeVersion records a copy of rec in the version history, replacing the
+// entry for rec.Version or appending one. Callers hold r.mu.
+func (r *InMemoryRepo) writeVersion(rec *SecretRecord) {
+	cp := rec.clone()
+	hist := r.versions[rec.Key]
+	for i, v := range hist {
+		if v.Version == rec.Version {
+			hist[i] = cp
+			return
+		}
+	}
+	r.versions[rec.Key] = append(hist, cp)
 }
 
 func (r *InMemoryRepo) GetSecret(ctx context.Context, key string) (*SecretRecord, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	if v, ok := r.data[key]; ok {
-		return v, nil
+		return v.clone(), nil
+	}
+	return nil, &Error{Op: "repo.GetSecret", Key: key, Err: ErrNotFound}
+}
+
+func (r *InMemoryRepo) CreateSecret(ctx context.Context, rec *SecretRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.data[rec.Key]; ok {
+		return &Error{Op: "repo.CreateSecret", Key: rec.Key, Store: rec.Store, Err: ErrAlreadyExists}
+	}
+	r.data[rec.Key] = rec.clone()
+	r.writeVersion(rec)
+	return nil
+}
+
+func (r *InMemoryRepo) UpdateSecret(ctx context.Context, rec *SecretRecord, pre Precondition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.data[rec.Key]
+	if !ok {
+		return &Error{Op: "repo.UpdateSecret", Key: rec.Key, Store: rec.Store, Err: ErrNotFound}
+	}
+	if !pre.check(cur) {
+		return pre.conflict("repo.UpdateSecret", rec.Key)
+	}
+	r.data[rec.Key] = rec.clone()
+	r.writeVersion(rec)
+	return nil
+}
+
+func (r *InMemoryRepo) SoftDeleteSecret(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.data[key]
+	if !ok {
+		return &Error{Op: "repo.SoftDeleteSecret", Key: key, Err: ErrNotFound}
+	}
+	next := *cur
+	next.Status = StatusDeleted
+	next.ModifiedAt = time.Now().UTC()
+	r.data[key] = &next
+	r.writeVersion(&next)
+	return nil
+}
+
+func (r *InMemoryRepo) HardDeleteSecret(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.data[key]; !ok {
+		return &Error{Op: "repo.HardDeleteSecret", Key: key, Err: ErrNotFound}
+	}
+	delete(r.data, key)
+	delete(r.versions, key)
+	return nil
+}
+
+func (r *InMemoryRepo) GetSecretVersion(ctx context.Context, key, version string) (*SecretRecord, error) {
+	if version == "" || version == VersionCurrent {
+		return r.GetSecret(ctx, key)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cur, ok := r.data[key]
+	if !ok {
+		return nil, &Error{Op: "repo.GetSecretVersion", Key: key, Err: ErrNotFound}
+	}
+	hist := r.versions[key]
+	for i, v := range hist {
+		switch {
+		case version == VersionPrevious && v.Version == cur.Version && i > 0:
+			return hist[i-1].clone(), nil
+		case v.Version == version:
+			return v.clone(), nil
+		}
+	}
+	return nil, errorf("repo.GetSecretVersion", key, ErrNotFound, "no version %q", version)
+}
+
+func (r *InMemoryRepo) ListVersions(ctx context.Context, key string) ([]*SecretRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hist := r.versions[key]
+	if len(hist) == 0 {
+		return nil, &Error{Op: "repo.ListVersions", Key: key, Err: ErrNotFound}
+	}
+	out := make([]*SecretRecord, len(hist))
+	for i, v := range hist {
+		out[i] = v.clone()
+	}
+	return out, nil
+}
+
+func (r *InMemoryRepo) ListSecrets(ctx context.Context, opts ListOptions) (*SecretPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.data))
+	for k, rec := range r.data {
+		if opts.matches(rec) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	limit := opts.limit()
+	if len(keys) > limit+1 {
+		keys = keys[:limit+1]
+	}
+	recs := make([]*SecretRecord, len(keys))
+	for i, k := range keys {
+		recs[i] = r.data[k].clone()
+	}
+	return newSecretPage(recs, limit), nil
+}
+
+func (r *InMemoryRepo) UpdateWrappedDEK(ctx context.Context, key, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.data[key]
+	if !ok || cur.WrappedDEK != oldWrappedDEK {
+		return errorf("repo.UpdateWrappedDEK", key, ErrConflict, "record missing or wrapped dek changed")
+	}
+	next := *cur
+	next.WrappedDEK = newWrappedDEK
+	next.KEKKeyID = newKEKKeyID
+	next.ModifiedAt = time.Now().UTC()
+	r.data[key] = &next
+	for i, v := range r.versions[key] {
+		if v.WrappedDEK == oldWrappedDEK {
+			rewrapped := *v
+			rewrapped.WrappedDEK, rewrapped.KEKKeyID, rewrapped.ModifiedAt = newWrappedDEK, newKEKKeyID, next.ModifiedAt
+			r.versions[key][i] = &rewrapped
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryRepo) UpdateVersionWrappedDEK(ctx context.Context, key, version, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, v := range r.versions[key] {
+		if v.Version == version && v.WrappedDEK == oldWrappedDEK {
+			rewrapped := *v
+			rewrapped.WrappedDEK, rewrapped.KEKKeyID, rewrapped.ModifiedAt = newWrappedDEK, newKEKKeyID, time.Now().UTC()
+			r.versions[key][i] = &rewrapped
+			return nil
+		}
 	}
-	return nil, nil
+	return errorf("repo.UpdateVersionWrappedDEK", key, ErrConflict, "version %q missing or wrapped dek changed", version)
 }
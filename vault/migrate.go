@@ -0,0 +1,271 @@
+package vault
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// migrationFS holds the canonical schema for the secrets table as numbered
+// up/down migrations. The files are templates over schemaVars, so Postgres
+// and SQLite (used in tests) are built from the same DDL, and name columns
+// through {{col "<default name>"}} so WithColumnMapping applies to them.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationsTable records which migrations have been applied to which table.
+const migrationsTable = "ds_vault_schema_migrations"
+
+type migration struct {
+	version  int
+	up, down string
+}
+
+// schemaVars are the values migration templates are executed with.
+type schemaVars struct {
+	Table    string // table as passed to Migrate, e.g. "public.secrets"
+	Schema   string // "public." or empty
+	Name     string // unqualified table name, prefix for index names
+	Postgres bool
+	UUID     string // column types
+	JSON     string
+	Time     string
+}
+
+func newSchemaVars(table string, postgres bool) schemaVars {
+	v := schemaVars{Table: table, Name: table, Postgres: postgres, UUID: "uuid", JSON: "jsonb", Time: "timestamptz"}
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		v.Schema, v.Name = table[:i+1], table[i+1:]
+	}
+	if !postgres {
+		// go-sqlite3 only decodes times from DATETIME-like column types.
+		v.UUID, v.Time = "text", "datetime"
+	}
+	return v
+}
+
+// loadMigrations parses the embedded migrations, ordered by version.
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrationFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, f := range files {
+		base := strings.TrimPrefix(f, "migrations/")
+		num, rest, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version", base)
+		}
+		body, err := migrationFS.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{version: version}
+			byVersion[version] = m
+		}
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			m.up = string(body)
+		case strings.HasSuffix(rest, ".down.sql"):
+			m.down = string(body)
+		default:
+			return nil, fmt.Errorf("migration %s: want .up.sql or .down.sql", base)
+		}
+	}
+	out := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	return out, nil
+}
+
+// Migrate creates table, or upgrades it to the latest schema, with its
+// indexes and constraints. Applied versions are recorded per table in
+// ds_vault_schema_migrations (in the table's schema), so Migrate is safe to
+// run on every start; on Postgres, concurrent runs are serialized with an
+// advisory lock. db may be Postgres or SQLite; the dialect is taken from
+// db's driver, and any driver whose type does not name SQLite is treated as
+// Postgres.
+//
+// An existing table that predates Migrate (no rows in
+// ds_vault_schema_migrations) is adopted: on Postgres the initial migration
+// adds the columns and indexes it lacks, and the later ones upgrade it in
+// place. On SQLite, which cannot add a column only if it is missing, the
+// table must already have the initial migration's columns.
+//
+// Of opts, only WithColumnMapping is used: pass the repository's mapping so
+// the table and its <table>_versions history get the same column names.
+func Migrate(ctx context.Context, db *sql.DB, table string, opts ...RepositoryOption) error {
+	const op = "Migrate"
+	m, err := newMigrator(ctx, op, db, table, opts)
+	if err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		if err := m.apply(ctx, mig.version, mig.up, true); err != nil {
+			return &Error{Op: op, Err: fmt.Errorf("migration %d: %w", mig.version, err)}
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts table's migrations newer than version, newest first.
+// Version 0 drops the table. opts must carry the WithColumnMapping that
+// Migrate was given, if any.
+func MigrateDown(ctx context.Context, db *sql.DB, table string, version int, opts ...RepositoryOption) error {
+	const op = "MigrateDown"
+	m, err := newMigrator(ctx, op, db, table, opts)
+	if err != nil {
+		return err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.version <= version {
+			break
+		}
+		if err := m.apply(ctx, mig.version, mig.down, false); err != nil {
+			return &Error{Op: op, Err: fmt.Errorf("migration %d: %w", mig.version, err)}
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the latest migration applied to table, or 0.
+func SchemaVersion(ctx context.Context, db *sql.DB, table string) (int, error) {
+	const op = "SchemaVersion"
+	m, err := newMigrator(ctx, op, db, table, nil)
+	if err != nil {
+		return 0, err
+	}
+	var v sql.NullInt64
+	err = db.QueryRowContext(ctx, "SELECT MAX(version) FROM "+m.vars.Schema+migrationsTable+" WHERE table_name = $1", table).Scan(&v)
+	if err != nil {
+		return 0, &Error{Op: op, Err: err}
+	}
+	return int(v.Int64), nil
+}
+
+type migrator struct {
+	db         *sql.DB
+	table      string
+	vars       schemaVars
+	columns    map[string]string // WithColumnMapping
+	migrations []migration
+}
+
+// newMigrator validates table and the column mapping, detects the dialect
+// and makes sure the migrations table exists.
+func newMigrator(ctx context.Context, op string, db *sql.DB, table string, opts []RepositoryOption) (*migrator, error) {
+	cfg, err := newRepoConfig(op, table, opts)
+	if err != nil {
+		return nil, err
+	}
+	migs, err := loadMigrations()
+	if err != nil {
+		return nil, &Error{Op: op, Err: err}
+	}
+	m := &migrator{db: db, table: table, vars: newSchemaVars(table, !isSQLite(db)), columns: cfg.columns, migrations: migs}
+
+	if err := m.createTracking(ctx); err != nil {
+		return nil, &Error{Op: op, Err: err}
+	}
+	return m, nil
+}
+
+// isSQLite reports whether db uses a SQLite driver (mattn/go-sqlite3,
+// modernc.org/sqlite and their wrappers all name it in their type). Asking
+// the server would turn any transient error into a wrong dialect.
+func isSQLite(db *sql.DB) bool {
+	return strings.Contains(strings.ToLower(fmt.Sprintf("%T", db.Driver())), "sqlite")
+}
+
+// createTracking creates the migrations table if it is missing. On Postgres
+// concurrent CREATE TABLE IF NOT EXISTS can still collide on the catalog, so
+// it runs under an advisory lock like the migrations themselves.
+func (m *migrator) createTracking(ctx context.Context) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if m.vars.Postgres {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", m.vars.Schema+migrationsTable); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+m.vars.Schema+migrationsTable+` (
+    table_name text NOT NULL,
+    version    integer NOT NULL,
+    applied_at `+m.vars.Time+` NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (table_name, version)
+)`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// col returns the table's name for the default column name.
+func (m *migrator) col(name string) string {
+	if c, ok := m.columns[name]; ok {
+		return c
+	}
+	return name
+}
+
+// apply runs one migration in a transaction, unless the migrations table
+// says it is already applied (up) or not applied (down).
+func (m *migrator) apply(ctx context.Context, version int, body string, up bool) error {
+	tmpl, err := template.New(strconv.Itoa(version)).Funcs(template.FuncMap{"col": m.col}).Parse(body)
+	if err != nil {
+		return err
+	}
+	var ddl strings.Builder
+	if err := tmpl.Execute(&ddl, m.vars); err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if m.vars.Postgres {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", migrationsTable+":"+m.table); err != nil {
+			return err
+		}
+	}
+	tracking := m.vars.Schema + migrationsTable
+	var n int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+tracking+" WHERE table_name = $1 AND version = $2", m.table, version).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if applied := n > 0; applied == up {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, ddl.String()); err != nil {
+		return err
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tracking+" (table_name, version) VALUES ($1, $2)", m.table, version)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+tracking+" WHERE table_name = $1 AND version = $2", m.table, version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
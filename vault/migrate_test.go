@@ -0,0 +1,131 @@
+package vault_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestMigrate_SQLite(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	gdb, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := gdb.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, vault.Migrate(ctx, db, "secrets"))
+	require.NoError(t, vault.Migrate(ctx, db, "secrets"), "re-running is a no-op")
+	v, err := vault.SchemaVersion(ctx, db, "secrets")
+	require.NoError(t, err)
+	require.Equal(t, 6, v)
+
+	insert := "INSERT INTO secrets (id, tenant_id, key) VALUES ($1, $2, $3)"
+	_, err = db.ExecContext(ctx, insert, uuid.NewString(), uuid.NewString(), "svc/a")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, insert, uuid.NewString(), uuid.NewString(), "svc/a")
+	require.Error(t, err, "key is unique")
+
+	// Tables are tracked separately.
+	v, err = vault.SchemaVersion(ctx, db, "other_secrets")
+	require.NoError(t, err)
+	require.Zero(t, v)
+
+	require.NoError(t, vault.MigrateDown(ctx, db, "secrets", 1))
+	v, err = vault.SchemaVersion(ctx, db, "secrets")
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	require.NoError(t, vault.MigrateDown(ctx, db, "secrets", 0))
+	_, err = db.ExecContext(ctx, "SELECT 1 FROM secrets")
+	require.Error(t, err, "table dropped")
+
+	require.NoError(t, vault.Migrate(ctx, db, "secrets"))
+	v, err = vault.SchemaVersion(ctx, db, "secrets")
+	require.NoError(t, err)
+	require.Equal(t, 6, v)
+
+	require.ErrorIs(t, vault.Migrate(ctx, db, "secrets; DROP TABLE x"), vault.ErrInvalidArgument)
+}
+
+func TestMigrate_AdoptsExistingTable(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	gdb, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := gdb.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// A deployment from before Migrate: the table exists, untracked.
+	_, err = db.ExecContext(ctx, `CREATE TABLE secrets (
+    id text PRIMARY KEY, tenant_id text NOT NULL, owner_id text, issuer text NOT NULL DEFAULT '',
+    name text NOT NULL DEFAULT '', version text NOT NULL DEFAULT '', description text,
+    status text NOT NULL DEFAULT 'active', metadata jsonb, tags jsonb,
+    created_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP, created_by text NOT NULL DEFAULT '',
+    modified_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP, modified_by text NOT NULL DEFAULT '',
+    key text NOT NULL, store text NOT NULL DEFAULT '', value text NOT NULL DEFAULT '', acl jsonb,
+    iv text NOT NULL DEFAULT '', tag text NOT NULL DEFAULT '', wrapped_dek text NOT NULL DEFAULT '',
+    kek_key_id text NOT NULL DEFAULT '', dek_alg text NOT NULL DEFAULT '', kek_alg text NOT NULL DEFAULT ''
+)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO secrets (id, tenant_id, key, version) VALUES ($1, $2, $3, $4)",
+		uuid.NewString(), uuid.NewString(), "svc/legacy", "v3")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO secrets (id, tenant_id, key, metadata) VALUES ($1, $2, $3, $4)",
+		uuid.NewString(), uuid.NewString(), "svc/ssm", `{"ssm_parameter_version":"7","team":"data"}`)
+	require.NoError(t, err)
+
+	require.NoError(t, vault.Migrate(ctx, db, "secrets"))
+	v, err := vault.SchemaVersion(ctx, db, "secrets")
+	require.NoError(t, err)
+	require.Equal(t, 6, v)
+
+	var scheme, version string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT aad_scheme FROM secrets WHERE key = $1", "svc/legacy").Scan(&scheme))
+	require.Empty(t, scheme)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT version FROM secrets_versions WHERE key = $1", "svc/legacy").Scan(&version))
+	require.Equal(t, "v3", version, "history backfilled from the adopted rows")
+	for _, table := range []string{"secrets", "secrets_versions"} {
+		var pinned string
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT store_version FROM "+table+" WHERE key = $1", "svc/ssm").Scan(&pinned))
+		require.Equal(t, "7", pinned, "SSM pin moved out of metadata in "+table)
+	}
+	require.NoError(t, vault.Migrate(ctx, db, "secrets"))
+}
+
+func TestMigrate_AdoptsPartialTablePostgres(t *testing.T) {
+	ctx := context.Background()
+	dsn := os.Getenv("DSVAULT_PG_DSN")
+	if dsn == "" {
+		t.Skip("DSVAULT_PG_DSN not set")
+	}
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	db, err := gdb.DB()
+	require.NoError(t, err)
+	table := "secrets_adopt_" + uuid.NewString()[:8]
+	t.Cleanup(func() { _ = vault.MigrateDown(ctx, db, table, 0) })
+
+	// An early deployment's table, without most of today's columns.
+	_, err = db.ExecContext(ctx, "CREATE TABLE "+table+" (id uuid PRIMARY KEY, tenant_id uuid NOT NULL, key text NOT NULL, value text NOT NULL DEFAULT '')")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO "+table+" (id, tenant_id, key) VALUES ($1, $2, $3)", uuid.New(), uuid.New(), "svc/legacy")
+	require.NoError(t, err)
+
+	require.NoError(t, vault.Migrate(ctx, db, table))
+	var status, kekAlg, scheme string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT status, kek_alg, aad_scheme FROM "+table+" WHERE key = $1", "svc/legacy").
+		Scan(&status, &kekAlg, &scheme))
+	require.Equal(t, "active", status, "missing columns added with their defaults")
+}
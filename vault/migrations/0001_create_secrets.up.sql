@@ -0,0 +1,60 @@
+-- Secrets table. Column names follow SecretRecord's field names in
+-- snake_case; WithColumnMapping covers tables that differ. A table that
+-- already exists (deployments that predate Migrate) is adopted: on Postgres
+-- the columns it lacks are added below. SQLite has no ADD COLUMN IF NOT
+-- EXISTS, so there an existing table must already have them.
+CREATE TABLE IF NOT EXISTS {{.Table}} (
+    {{col "id"}} {{.UUID}} PRIMARY KEY,
+    {{col "tenant_id"}} {{.UUID}} NOT NULL,
+    {{col "owner_id"}} text,
+    {{col "issuer"}} text NOT NULL DEFAULT '',
+    {{col "name"}} text NOT NULL DEFAULT '',
+    {{col "version"}} text NOT NULL DEFAULT '',
+    {{col "description"}} text,
+    {{col "status"}} text NOT NULL DEFAULT 'active',
+    {{col "metadata"}} {{.JSON}},
+    {{col "tags"}} {{.JSON}},
+    {{col "created_at"}} {{.Time}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    {{col "created_by"}} text NOT NULL DEFAULT '',
+    {{col "modified_at"}} {{.Time}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    {{col "modified_by"}} text NOT NULL DEFAULT '',
+    {{col "key"}} text NOT NULL,
+    {{col "store"}} text NOT NULL DEFAULT '',
+    {{col "value"}} text NOT NULL DEFAULT '',
+    {{col "acl"}} {{.JSON}},
+    {{col "iv"}} text NOT NULL DEFAULT '',
+    {{col "tag"}} text NOT NULL DEFAULT '',
+    {{col "wrapped_dek"}} text NOT NULL DEFAULT '',
+    {{col "kek_key_id"}} text NOT NULL DEFAULT '',
+    {{col "dek_alg"}} text NOT NULL DEFAULT '',
+    {{col "kek_alg"}} text NOT NULL DEFAULT ''
+);
+{{if .Postgres}}
+-- tenant_id and key have no default: a populated table without them is not
+-- a secrets table, and the migration fails rather than adopting it.
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "tenant_id"}} {{.UUID}} NOT NULL;
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "owner_id"}} text;
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "issuer"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "name"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "version"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "description"}} text;
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "status"}} text NOT NULL DEFAULT 'active';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "metadata"}} {{.JSON}};
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "tags"}} {{.JSON}};
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "created_at"}} {{.Time}} NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "created_by"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "modified_at"}} {{.Time}} NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "modified_by"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "key"}} text NOT NULL;
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "store"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "value"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "acl"}} {{.JSON}};
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "iv"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "tag"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "wrapped_dek"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "kek_key_id"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "dek_alg"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS {{col "kek_alg"}} text NOT NULL DEFAULT '';
+{{end}}
+
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Name}}_key_idx ON {{.Table}} ({{col "key"}});
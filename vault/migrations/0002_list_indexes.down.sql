@@ -0,0 +1,4 @@
+{{- if .Postgres}}
+DROP INDEX {{.Schema}}{{.Name}}_tags_idx;
+{{- end}}
+DROP INDEX {{.Schema}}{{.Name}}_tenant_idx;
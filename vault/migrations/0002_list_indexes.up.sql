@@ -0,0 +1,5 @@
+-- Indexes for ListSecrets filters.
+CREATE INDEX IF NOT EXISTS {{.Name}}_tenant_idx ON {{.Table}} ({{col "tenant_id"}}, {{col "key"}});
+{{- if .Postgres}}
+CREATE INDEX IF NOT EXISTS {{.Name}}_tags_idx ON {{.Table}} USING gin ({{col "tags"}} jsonb_path_ops);
+{{- end}}
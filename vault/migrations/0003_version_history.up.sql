@@ -0,0 +1,53 @@
+-- Version history: one row per (key, version), in the order versions were
+-- first written (seq). Repository writes keep the row for the record's
+-- current version in step with the record; older versions are never
+-- rewritten, except for their wrapped DEK when it is re-wrapped.
+CREATE TABLE IF NOT EXISTS {{.Table}}_versions (
+    seq         integer NOT NULL,
+    {{col "id"}} {{.UUID}} NOT NULL,
+    {{col "tenant_id"}} {{.UUID}} NOT NULL,
+    {{col "owner_id"}} text,
+    {{col "issuer"}} text NOT NULL DEFAULT '',
+    {{col "name"}} text NOT NULL DEFAULT '',
+    {{col "version"}} text NOT NULL DEFAULT '',
+    {{col "description"}} text,
+    {{col "status"}} text NOT NULL DEFAULT 'active',
+    {{col "metadata"}} {{.JSON}},
+    {{col "tags"}} {{.JSON}},
+    {{col "created_at"}} {{.Time}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    {{col "created_by"}} text NOT NULL DEFAULT '',
+    {{col "modified_at"}} {{.Time}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    {{col "modified_by"}} text NOT NULL DEFAULT '',
+    {{col "key"}} text NOT NULL,
+    {{col "store"}} text NOT NULL DEFAULT '',
+    {{col "value"}} text NOT NULL DEFAULT '',
+    {{col "acl"}} {{.JSON}},
+    {{col "iv"}} text NOT NULL DEFAULT '',
+    {{col "tag"}} text NOT NULL DEFAULT '',
+    {{col "wrapped_dek"}} text NOT NULL DEFAULT '',
+    {{col "kek_key_id"}} text NOT NULL DEFAULT '',
+    {{col "dek_alg"}} text NOT NULL DEFAULT '',
+    {{col "kek_alg"}} text NOT NULL DEFAULT '',
+    PRIMARY KEY ({{col "key"}}, seq)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Name}}_versions_version_idx ON {{.Table}}_versions ({{col "key"}}, {{col "version"}});
+
+INSERT INTO {{.Table}}_versions (
+    seq, {{col "id"}}, {{col "tenant_id"}}, {{col "owner_id"}}, {{col "issuer"}},
+    {{col "name"}}, {{col "version"}}, {{col "description"}}, {{col "status"}},
+    {{col "metadata"}}, {{col "tags"}}, {{col "created_at"}}, {{col "created_by"}},
+    {{col "modified_at"}}, {{col "modified_by"}}, {{col "key"}}, {{col "store"}},
+    {{col "value"}}, {{col "acl"}}, {{col "iv"}}, {{col "tag"}}, {{col "wrapped_dek"}},
+    {{col "kek_key_id"}}, {{col "dek_alg"}}, {{col "kek_alg"}}
+)
+SELECT
+    1, s.{{col "id"}}, s.{{col "tenant_id"}}, s.{{col "owner_id"}}, s.{{col "issuer"}},
+    s.{{col "name"}}, s.{{col "version"}}, s.{{col "description"}}, s.{{col "status"}},
+    s.{{col "metadata"}}, s.{{col "tags"}}, s.{{col "created_at"}}, s.{{col "created_by"}},
+    s.{{col "modified_at"}}, s.{{col "modified_by"}}, s.{{col "key"}}, s.{{col "store"}},
+    s.{{col "value"}}, s.{{col "acl"}}, s.{{col "iv"}}, s.{{col "tag"}},
+    s.{{col "wrapped_dek"}}, s.{{col "kek_key_id"}}, s.{{col "dek_alg"}},
+    s.{{col "kek_alg"}}
+FROM {{.Table}} AS s
+WHERE NOT EXISTS (SELECT 1 FROM {{.Table}}_versions AS v WHERE v.{{col "key"}} = s.{{col "key"}});
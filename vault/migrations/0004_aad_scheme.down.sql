@@ -0,0 +1,2 @@
+ALTER TABLE {{.Table}}_versions DROP COLUMN {{col "aad_scheme"}};
+ALTER TABLE {{.Table}} DROP COLUMN {{col "aad_scheme"}};
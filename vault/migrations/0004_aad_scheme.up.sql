@@ -0,0 +1,4 @@
+-- AAD scheme of each record (SecretRecord.AADScheme). Existing rows were
+-- sealed under aad_v1; the empty default reads as aad_v1.
+ALTER TABLE {{.Table}} ADD COLUMN {{if .Postgres}}IF NOT EXISTS {{end}}{{col "aad_scheme"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}}_versions ADD COLUMN {{if .Postgres}}IF NOT EXISTS {{end}}{{col "aad_scheme"}} text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE {{.Table}}_versions DROP COLUMN {{col "commitment"}};
+ALTER TABLE {{.Table}} DROP COLUMN {{col "commitment"}};
@@ -0,0 +1,4 @@
+-- Key commitment of records sealed with a committing DEKAlg
+-- (SecretRecord.Commitment); empty for every other algorithm.
+ALTER TABLE {{.Table}} ADD COLUMN {{if .Postgres}}IF NOT EXISTS {{end}}{{col "commitment"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}}_versions ADD COLUMN {{if .Postgres}}IF NOT EXISTS {{end}}{{col "commitment"}} text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE {{.Table}}_versions DROP COLUMN {{col "store_version"}};
+ALTER TABLE {{.Table}} DROP COLUMN {{col "store_version"}};
@@ -0,0 +1,16 @@
+-- External store version pinned by a record (SecretRecord.StoreVersion),
+-- e.g. the SSM parameter version. Older SDKs kept the SSM pin in metadata
+-- under ssm_parameter_version; it is copied over, and left in metadata.
+ALTER TABLE {{.Table}} ADD COLUMN {{if .Postgres}}IF NOT EXISTS {{end}}{{col "store_version"}} text NOT NULL DEFAULT '';
+ALTER TABLE {{.Table}}_versions ADD COLUMN {{if .Postgres}}IF NOT EXISTS {{end}}{{col "store_version"}} text NOT NULL DEFAULT '';
+{{- if .Postgres}}
+UPDATE {{.Table}} SET {{col "store_version"}} = {{col "metadata"}} ->> 'ssm_parameter_version'
+WHERE {{col "metadata"}} ->> 'ssm_parameter_version' IS NOT NULL;
+UPDATE {{.Table}}_versions SET {{col "store_version"}} = {{col "metadata"}} ->> 'ssm_parameter_version'
+WHERE {{col "metadata"}} ->> 'ssm_parameter_version' IS NOT NULL;
+{{- else}}
+UPDATE {{.Table}} SET {{col "store_version"}} = json_extract(CAST({{col "metadata"}} AS TEXT), '$.ssm_parameter_version')
+WHERE json_extract(CAST({{col "metadata"}} AS TEXT), '$.ssm_parameter_version') IS NOT NULL;
+UPDATE {{.Table}}_versions SET {{col "store_version"}} = json_extract(CAST({{col "metadata"}} AS TEXT), '$.ssm_parameter_version')
+WHERE json_extract(CAST({{col "metadata"}} AS TEXT), '$.ssm_parameter_version') IS NOT NULL;
+{{- end}}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package vault
+
+import "syscall"
+
+// lockedAlloc maps n bytes of anonymous memory and locks them into RAM.
+func lockedAlloc(n int) ([]byte, error) {
+	b, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Mlock(b); err != nil {
+		_ = syscall.Munmap(b)
+		return nil, err
+	}
+	return b, nil
+}
+
+// lockedFree unlocks and unmaps memory from lockedAlloc.
+func lockedFree(b []byte) {
+	_ = syscall.Munlock(b)
+	_ = syscall.Munmap(b)
+}
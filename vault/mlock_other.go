@@ -0,0 +1,13 @@
+//go:build !linux
+
+package vault
+
+import "errors"
+
+// lockedAlloc is unavailable off Linux; SecureBytes falls back to ordinary
+// memory.
+func lockedAlloc(n int) ([]byte, error) {
+	return nil, errors.New("memory locking not supported on this platform")
+}
+
+func lockedFree(b []byte) {}
@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// NegativeCacheStats reports on a negative cache, which remembers keys that
+// were not found so that repeated reads of a missing key do not reach the
+// database.
+type NegativeCacheStats struct {
+	Hits    uint64 // reads answered with ErrNotFound from the negative cache
+	Stores  uint64 // not-found results recorded
+	Entries int    // keys currently remembered
+}
+
+// negativeCache remembers not-found keys, separately from positive entries
+// so they can have their own TTL and be purged on their own. A nil
+// *negativeCache is a disabled cache. Internal helper; not exported.
+type negativeCache struct {
+	keys   *TTLCache[struct{}]
+	hits   atomic.Uint64
+	stores atomic.Uint64
+}
+
+// negativeCacheSize bounds the keys a negative cache remembers, so a flood of
+// distinct bad keys cannot grow it without limit.
+const negativeCacheSize = 4096
+
+// newNegativeCache returns a negative cache with the given TTL, or nil
+// (disabled) if ttl <= 0.
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &negativeCache{keys: NewTTLCache[struct{}](negativeCacheSize, ttl)}
+}
+
+// has reports whether key is known to be missing, counting a hit if so.
+func (n *negativeCache) has(key string) bool {
+	if n == nil {
+		return false
+	}
+	if _, ok := n.keys.Get(key); !ok {
+		return false
+	}
+	n.hits.Add(1)
+	return true
+}
+
+func (n *negativeCache) add(key string) {
+	if n == nil {
+		return
+	}
+	n.keys.Set(key, struct{}{})
+	n.stores.Add(1)
+}
+
+func (n *negativeCache) forget(key string) {
+	if n != nil {
+		n.keys.Delete(key)
+	}
+}
+
+func (n *negativeCache) purge() {
+	if n != nil {
+		n.keys.Clear()
+	}
+}
+
+func (n *negativeCache) stats() NegativeCacheStats {
+	if n == nil {
+		return NegativeCacheStats{}
+	}
+	return NegativeCacheStats{Hits: n.hits.Load(), Stores: n.stores.Load(), Entries: n.keys.Len()}
+}
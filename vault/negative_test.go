@@ -0,0 +1,98 @@
+package vault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestClient_NegativeCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := &stubRepo{}
+	client := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute,
+		vault.WithNegativeCache(time.Minute))
+
+	for range 3 {
+		_, err := client.GetSecret(ctx, "/bad/path")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+	}
+	require.Equal(t, 1, repo.calls)
+	require.Equal(t, vault.NegativeCacheStats{Hits: 2, Stores: 1, Entries: 1}, client.NegativeCacheStats())
+
+	client.PurgeNegativeCache()
+	require.Equal(t, 0, client.NegativeCacheStats().Entries)
+	_, err := client.GetSecret(ctx, "/bad/path")
+	require.ErrorIs(t, err, vault.ErrNotFound)
+	require.Equal(t, 2, repo.calls)
+
+	// Without the option nothing is remembered.
+	plain := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute)
+	_, _ = plain.GetSecret(ctx, "/bad/path")
+	_, _ = plain.GetSecret(ctx, "/bad/path")
+	require.Equal(t, 4, repo.calls)
+	require.Equal(t, vault.NegativeCacheStats{}, plain.NegativeCacheStats())
+}
+
+func TestClient_NegativeCache_ForgottenOnPutAndExpiry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	client := vault.NewClient(vault.NewInMemoryRepo(), vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute,
+		vault.WithNegativeCache(time.Minute), openACL)
+
+	_, err := client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrNotFound)
+
+	_, err = client.PutSecret(ctx, key, []byte("now it exists"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("now it exists"), pt)
+
+	short := vault.NewClient(&stubRepo{}, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute,
+		vault.WithNegativeCache(20*time.Millisecond))
+	_, _ = short.GetSecret(ctx, "/bad/path")
+	require.Eventually(t, func() bool {
+		_, _ = short.GetSecret(ctx, "/bad/path")
+		return short.NegativeCacheStats().Stores == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPostgresSecretRepository_NegativeCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	_ = fakes.NewDB(t, dsn)
+
+	repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+	require.NoError(t, err)
+	repo.SetNegativeCacheTTL(time.Minute)
+
+	for range 3 {
+		_, err := repo.GetSecret(ctx, "svc/missing")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+	}
+	require.Equal(t, vault.NegativeCacheStats{Hits: 2, Stores: 1, Entries: 1}, repo.NegativeCacheStats())
+
+	// A write through the repository forgets the negative entry.
+	require.NoError(t, repo.CreateSecret(ctx, &vault.SecretRecord{ID: uuid.New(), Key: "svc/missing", Status: vault.StatusActive}))
+	got, err := repo.GetSecret(ctx, "svc/missing")
+	require.NoError(t, err)
+	require.Equal(t, "svc/missing", got.Key)
+
+	_, _ = repo.GetSecret(ctx, "svc/other")
+	require.Equal(t, 1, repo.NegativeCacheStats().Entries)
+	repo.PurgeNegativeCache()
+	require.Equal(t, 0, repo.NegativeCacheStats().Entries)
+}
@@ -0,0 +1,244 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grasp-labs/ds-go-commonmodels/v2/commonmodels/types"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// PutOptions describes the record written by Client.PutSecret.
+// TenantID and KEKKeyID are required; everything else has a default.
+type PutOptions struct {
+	TenantID uuid.UUID
+	ID       uuid.UUID // defaults to the key's secret id, which it must equal
+	Store    Store     // defaults to StoreDSVault
+	KEKKeyID string    // KMS key id/arn the DEK is wrapped under
+	DEKAlg   string    // Cipher the DEK seals with; defaults to DEKAlgAES256GCM
+	Envelope bool      // store the ciphertext as a single Envelope (see Envelope)
+
+	Name        string
+	Issuer      string
+	Version     string // defaults to "v1"
+	Description *string
+	OwnerID     *string
+	Status      Status // defaults to StatusActive
+	Metadata    map[string]string
+	Tags        map[string]string
+	ACL         map[string][]string
+	CreatedBy   string
+}
+
+// PutSecret envelope-encrypts plaintext and persists it under key.
+//
+// Flow on PutSecret:
+//  1. Derive AAD and KMS EncryptionContext using MakeRecordAADAndEncCtx under
+//     AADSchemeV2; key must be canonical (see ParseKey).
+//  2. Generate a fresh DEK under opts.KEKKeyID (KMS GenerateDataKey, or the
+//     Client's KeyProvider).
+//  3. Encrypt with the Cipher for opts.DEKAlg (AES-256-GCM by default) using
+//     (DEK, random IV, AAD).
+//  4. Write Base64(ciphertext) through the CiphertextWriter registered for
+//     Store (SSM under key, rec.Value for the DB). IV, Tag and the wrapped
+//     DEK go on the record, or with opts.Envelope into a single Envelope
+//     written instead of the ciphertext.
+//  5. Insert the SecretRecord through the repository (must implement SecretWriter).
+//
+// External stores are written without overwrite, so an existing SSM
+// parameter is never clobbered. If the insert fails, the ciphertext is
+// removed again from stores implementing CiphertextDeleter, unless the
+// record may have been written after all (see removeUnreferenced).
+// The plaintext is not cached; the first GetSecret loads it.
+func (c *Client) PutSecret(ctx context.Context, key string, plaintext []byte, opts PutOptions) (*SecretRecord, error) {
+	const op = "Client.PutSecret"
+	w, ok := c.repo.(SecretWriter)
+	if !ok {
+		return nil, errorf(op, key, ErrNotSupported, "repository %T does not support writes", c.repo)
+	}
+	if key == "" {
+		return nil, errorf(op, key, ErrInvalidArgument, "key is required")
+	}
+	if opts.TenantID == uuid.Nil {
+		return nil, errorf(op, key, ErrInvalidArgument, "tenant id is required")
+	}
+	if opts.Store == "" {
+		opts.Store = StoreDSVault
+	}
+	cw, err := c.writer(opts.Store)
+	if err != nil {
+		return nil, withKey(err, op, key, opts.Store)
+	}
+	k, err := parseKey(key, true)
+	if err != nil {
+		return nil, withKey(err, op, key, opts.Store)
+	}
+	switch {
+	case opts.ID == uuid.Nil:
+		opts.ID = k.SecretID
+	case opts.ID != k.SecretID:
+		return nil, errorf(op, key, ErrKeyMismatch, "key names secret %s, id is %s", k.SecretID, opts.ID)
+	}
+	if opts.Version == "" {
+		opts.Version = "v1"
+	}
+	if opts.Status == "" {
+		opts.Status = StatusActive
+	}
+
+	now := time.Now().UTC()
+	rec := &SecretRecord{
+		ID:          opts.ID,
+		TenantID:    opts.TenantID,
+		OwnerID:     opts.OwnerID,
+		Issuer:      opts.Issuer,
+		Name:        opts.Name,
+		Version:     opts.Version,
+		Description: opts.Description,
+		Status:      opts.Status,
+		Metadata:    types.JSONB[map[string]string]{Data: opts.Metadata},
+		Tags:        types.JSONB[map[string]string]{Data: opts.Tags},
+		CreatedAt:   now,
+		CreatedBy:   opts.CreatedBy,
+		ModifiedAt:  now,
+		ModifiedBy:  opts.CreatedBy,
+		Key:         key,
+		Store:       opts.Store,
+		ACL:         types.JSONB[map[string][]string]{Data: opts.ACL},
+		KEKKeyID:    opts.KEKKeyID,
+		DEKAlg:      opts.DEKAlg,
+	}
+
+	valueB64, err := c.seal(ctx, rec, plaintext, opts.Envelope)
+	if err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	if err := cw.PutCiphertext(ctx, rec, valueB64, false); err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	if err := w.CreateSecret(ctx, rec); err != nil {
+		// Without the record nothing references the ciphertext; remove it
+		// so a retry is not rejected by the store.
+		if d, ok := cw.(CiphertextDeleter); ok {
+			err = c.removeUnreferenced(ctx, d, rec, err)
+		}
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	c.negative.forget(key)
+	return rec, nil
+}
+
+// rollbackTimeout bounds the read-back and delete of removeUnreferenced,
+// which cannot use the caller's context: it may be what failed the insert.
+const rollbackTimeout = 10 * time.Second
+
+// removeUnreferenced deletes the ciphertext written for rec after its insert
+// failed with err, and returns err annotated with the outcome. A duplicate
+// key or another constraint violation means nothing was written. Any other
+// error (a timeout, a dropped connection) may have hit after the commit, so
+// the key is read back and the ciphertext is only deleted when no record
+// exists.
+func (c *Client) removeUnreferenced(ctx context.Context, d CiphertextDeleter, rec *SecretRecord, err error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if !errors.Is(err, ErrAlreadyExists) && !isConstraintViolation(err) {
+		if inv, ok := c.repo.(KeyInvalidator); ok {
+			inv.Invalidate(rec.Key)
+		}
+		_, getErr := c.repo.GetSecret(ctx, rec.Key)
+		switch {
+		case getErr == nil:
+			return fmt.Errorf("%w (record found on read-back, ciphertext kept)", err)
+		case !errors.Is(getErr, ErrNotFound):
+			return fmt.Errorf("%w (ciphertext kept, read-back failed: %v)", err, getErr)
+		}
+	}
+	if rbErr := d.DeleteCiphertext(ctx, rec); rbErr != nil {
+		return fmt.Errorf("%w (removing ciphertext: %v)", err, rbErr)
+	}
+	return err
+}
+
+// isConstraintViolation reports whether err is a constraint violation from
+// gorm (with TranslateError) or Postgres (SQLSTATE class 23).
+func isConstraintViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, gorm.ErrCheckConstraintViolated) ||
+		errors.Is(err, gorm.ErrForeignKeyViolated) || errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "23")
+}
+
+// seal generates a DEK for rec, encrypts plaintext under it with the Cipher
+// for rec.DEKAlg (DEKAlgAES256GCM when empty) and the AADSchemeV2 AAD, and
+// stores the IV, Tag, wrapped DEK, DEKAlg, KEKAlg, AADScheme and (for a
+// CommittingCipher) Commitment on rec. It returns Base64(ciphertext). With
+// envelope set it returns the Base64 Envelope instead and leaves IV, Tag,
+// WrappedDEK and Commitment empty.
+func (c *Client) seal(ctx context.Context, rec *SecretRecord, plaintext []byte, envelope bool) (string, error) {
+	const op = "Client.seal"
+	kp, ok := c.keys.(KeyProvider)
+	if !ok {
+		return "", errorf(op, rec.Key, ErrNotSupported, "key unwrapper %T cannot generate DEKs", c.keys)
+	}
+	alg := rec.DEKAlg
+	if alg == "" {
+		alg = DEKAlgAES256GCM
+	}
+	ci, err := c.cipher(op, alg)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := envelopeAlgIDs[alg]; envelope && !ok {
+		return "", &Error{Op: op, Err: fmt.Errorf("%w: dek algorithm %q has no envelope id", ErrUnknownAlgorithm, alg)}
+	}
+	rec.AADScheme = AADSchemeV2
+	aad, encCtx, err := MakeRecordAADAndEncCtx(rec)
+	if err != nil {
+		return "", err
+	}
+	dek, wrapped, err := kp.GenerateDEK(ctx, rec.KEKKeyID, encCtx)
+	if err != nil {
+		return "", err
+	}
+	defer clear(dek)
+	aead, err := newAEAD(op, alg, ci, dek, ErrInvalidArgument)
+	if err != nil {
+		return "", err
+	}
+	commitment, err := commit(op, ci, dek)
+	if err != nil {
+		return "", err
+	}
+	rec.DEKAlg = alg
+	rec.KEKAlg = kp.KEKAlg()
+	enc := base64.StdEncoding
+	if envelope {
+		e := &Envelope{Version: EnvelopeVersion1, DEKAlg: alg, KEKKeyID: rec.KEKKeyID, Commitment: commitment}
+		if e.WrappedDEK, err = enc.DecodeString(wrapped); err != nil {
+			return "", errorf(op, rec.Key, ErrInvalidCiphertext, "wrapped dek base64: %v", err)
+		}
+		if err := e.seal(aead, plaintext, aad); err != nil {
+			return "", err
+		}
+		rec.IV, rec.Tag, rec.WrappedDEK, rec.Commitment = "", "", "", ""
+		return e.Encode()
+	}
+	iv, sealed, err := encryptAEAD(aead, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	n := len(sealed) - aead.Overhead()
+	rec.IV = enc.EncodeToString(iv)
+	rec.Tag = enc.EncodeToString(sealed[n:])
+	rec.WrappedDEK = wrapped
+	rec.Commitment = ""
+	if commitment != nil {
+		rec.Commitment = enc.EncodeToString(commitment)
+	}
+	return enc.EncodeToString(sealed[:n]), nil
+}
@@ -0,0 +1,278 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestClient_PutSecret_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM} {
+		t.Run(string(store), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			tenantID := uuid.New()
+			secretID := uuid.New()
+			key := vault.MakeKey(secretID, tenantID, string(store), string(vault.EnvDev), "ds", "vault")
+			_, encCtx, err := vault.MakeRecordAADAndEncCtx(&vault.SecretRecord{
+				ID: secretID, TenantID: tenantID, Key: key, Store: store, Version: "v1", AADScheme: vault.AADSchemeV2,
+			})
+			require.NoError(t, err)
+			require.Equal(t, "v1", encCtx["version"])
+
+			kekKeyID := "arn:aws:kms:eu-north-1:111122223333:key/put"
+			kmsFake := &fakes.KMS{ExpectEncCtx: encCtx, ExpectKeyID: kekKeyID}
+			ssmFake := &fakes.SSM{Values: map[string]string{}}
+			repo := vault.NewInMemoryRepo()
+
+			client := vault.NewClient(repo,
+				vault.NewKMSProvider(kmsFake, 1024, 5*time.Minute),
+				vault.NewSSMProvider(ssmFake, 1024, 5*time.Minute),
+				time.Minute, openACL)
+
+			rec, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{
+				TenantID: tenantID,
+				ID:       secretID,
+				Store:    store,
+				KEKKeyID: kekKeyID,
+				Name:     "db_password",
+			})
+			require.NoError(t, err)
+			require.Equal(t, 1, kmsFake.GenerateCalls)
+			require.Equal(t, "v1", rec.Version)
+			require.Equal(t, vault.StatusActive, rec.Status)
+			require.Equal(t, vault.DEKAlgAES256GCM, rec.DEKAlg)
+			require.Equal(t, vault.AADSchemeV2, rec.AADScheme)
+			require.NotEmpty(t, rec.IV)
+			require.NotEmpty(t, rec.Tag)
+			require.NotEmpty(t, rec.WrappedDEK)
+
+			if store == vault.StoreAWSSSM {
+				require.Empty(t, rec.Value)
+				require.NotEmpty(t, ssmFake.Values[key])
+			} else {
+				require.NotEmpty(t, rec.Value)
+				require.Equal(t, 0, ssmFake.PutCalls)
+			}
+
+			pt, err := client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, []byte("s3cr3t"), pt)
+
+			_, err = client.PutSecret(ctx, key, []byte("again"), vault.PutOptions{
+				TenantID: tenantID,
+				Store:    store,
+				KEKKeyID: kekKeyID,
+			})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestClient_PutSecret_PostgresRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	_ = fakes.NewDB(t, dsn)
+	repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+	require.NoError(t, err)
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvProd), "ds", "vault")
+	kmsFake := &fakes.KMS{}
+	client := vault.NewClient(repo,
+		vault.NewKMSProvider(kmsFake, 1024, 5*time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{}, 1024, 5*time.Minute),
+		time.Minute)
+
+	_, err = client.PutSecret(ctx, key, []byte("pg-secret"), vault.PutOptions{
+		TenantID: tenantID,
+		KEKKeyID: "alias/ds-vault",
+		Tags:     map[string]string{"team": "billing"},
+	})
+	require.NoError(t, err)
+
+	// A second repository instance has a cold cache and must read the row back.
+	repo2, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+	require.NoError(t, err)
+	got, err := repo2.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, tenantID, got.TenantID)
+	require.Equal(t, "billing", got.Tags.Data["team"])
+
+	client2 := vault.NewClient(repo2,
+		vault.NewKMSProvider(kmsFake, 1024, 5*time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{}, 1024, 5*time.Minute),
+		time.Minute, openACL)
+	pt, err := client2.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("pg-secret"), pt)
+}
+
+func TestClient_PutSecret_ReturnedRecordIsACopy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	_ = fakes.NewDB(t, dsn)
+	gormRepo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+	require.NoError(t, err)
+
+	for name, repo := range map[string]vault.SecretRepository{"memory": vault.NewInMemoryRepo(), "gorm": gormRepo} {
+		tenantID := uuid.New()
+		key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvProd), "ds", "vault")
+		client := vault.NewClient(repo,
+			vault.NewKMSProvider(&fakes.KMS{}, 1024, 5*time.Minute),
+			vault.NewSSMProvider(&fakes.SSM{}, 1024, 5*time.Minute),
+			time.Minute)
+
+		rec, err := client.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{
+			TenantID: tenantID,
+			KEKKeyID: "alias/ds-vault",
+			Metadata: map[string]string{"owner": "billing"},
+			Tags:     map[string]string{"team": "billing"},
+			ACL:      map[string][]string{"read": {"role:billing"}},
+		})
+		require.NoError(t, err, name)
+		rec.Metadata.Data["owner"] = "changed"
+		rec.Tags.Data["team"] = "changed"
+		rec.ACL.Data["read"][0] = "role:changed"
+
+		got, err := repo.GetSecret(ctx, key)
+		require.NoError(t, err, name)
+		require.Equal(t, "billing", got.Metadata.Data["owner"], name)
+		require.Equal(t, "billing", got.Tags.Data["team"], name)
+		require.Equal(t, []string{"role:billing"}, got.ACL.Data["read"], name)
+
+		// Nor does changing what GetSecret returned.
+		got.Tags.Data["team"] = "changed"
+		again, err := repo.GetSecret(ctx, key)
+		require.NoError(t, err, name)
+		require.Equal(t, "billing", again.Tags.Data["team"], name)
+	}
+}
+
+func TestClient_PutSecret_ReadOnlyAWSClients(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	kmsFake := &fakes.KMS{}
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	repo := vault.NewInMemoryRepo()
+	writer := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), vault.NewSSMProvider(ssmFake, 16, time.Minute), time.Minute)
+	_, err := writer.PutSecret(ctx, key, []byte("s3cr3t"), vault.PutOptions{TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+
+	// Adapters that only implement the read methods still compile and read.
+	reader := vault.NewClient(repo,
+		vault.NewKMSProvider(struct{ vault.KMSAPI }{kmsFake}, 16, time.Minute),
+		vault.NewSSMProvider(struct{ vault.SSMAPI }{ssmFake}, 16, time.Minute), time.Minute, openACL)
+	pt, err := reader.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", string(pt))
+
+	other := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	_, err = reader.PutSecret(ctx, other, []byte("x"), vault.PutOptions{TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "alias/ds-vault"})
+	require.ErrorIs(t, err, vault.ErrNotSupported)
+
+	_, err = vault.NewKMSProvider(struct{ vault.KMSAPI }{kmsFake}, 16, time.Minute).
+		RewrapDEK(ctx, "V1JBUFBFRA==", nil, "alias/ds-vault", "alias/next")
+	require.ErrorIs(t, err, vault.ErrNotSupported)
+}
+
+// failingCreate is a repository whose inserts fail.
+type failingCreate struct{ *vault.InMemoryRepo }
+
+func (failingCreate) CreateSecret(context.Context, *vault.SecretRecord) error {
+	return errors.New("insert failed")
+}
+
+func TestClient_PutSecret_RemovesCiphertextWhenInsertFails(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	kmsProv := vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute)
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	ssmProv := vault.NewSSMProvider(ssmFake, 16, time.Minute)
+	repo := vault.NewInMemoryRepo()
+	opts := vault.PutOptions{TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "alias/ds-vault"}
+
+	_, err := vault.NewClient(failingCreate{repo}, kmsProv, ssmProv, time.Minute).PutSecret(ctx, key, []byte("s3cr3t"), opts)
+	require.ErrorContains(t, err, "insert failed")
+	require.NotContains(t, ssmFake.Values, key, "parameter removed after the failed insert")
+
+	// A retry is not rejected by a leftover parameter.
+	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute, openACL)
+	_, err = client.PutSecret(ctx, key, []byte("s3cr3t"), opts)
+	require.NoError(t, err)
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", string(pt))
+}
+
+// flakyCreateRepo fails CreateSecret with err, after inserting the record
+// when commit is set (a timeout that hit after the commit).
+type flakyCreateRepo struct {
+	*vault.InMemoryRepo
+	err    error
+	commit bool
+}
+
+func (r *flakyCreateRepo) CreateSecret(ctx context.Context, rec *vault.SecretRecord) error {
+	if r.commit {
+		if err := r.InMemoryRepo.CreateSecret(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return r.err
+}
+
+func TestClient_PutSecret_RollsBackOnlyUnreferencedCiphertext(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		err     error
+		commit  bool
+		removed bool
+	}{
+		"duplicate key":       {err: vault.ErrAlreadyExists, removed: true},
+		"constraint":          {err: gorm.ErrCheckConstraintViolated, removed: true},
+		"timeout, not stored": {err: context.DeadlineExceeded, removed: true},
+		"timeout, committed":  {err: context.DeadlineExceeded, commit: true},
+	} {
+		tenantID := uuid.New()
+		key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+		ssmFake := &fakes.SSM{Values: map[string]string{}}
+		repo := &flakyCreateRepo{InMemoryRepo: vault.NewInMemoryRepo(), err: tc.err, commit: tc.commit}
+		client := vault.NewClient(repo, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute),
+			vault.NewSSMProvider(ssmFake, 16, time.Minute), time.Minute, openACL)
+
+		_, err := client.PutSecret(ctx, key, []byte("x"), vault.PutOptions{TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "k"})
+		require.ErrorIs(t, err, tc.err, name)
+		_, exists := ssmFake.Values[key]
+		require.Equal(t, !tc.removed, exists, name)
+		if tc.commit {
+			pt, err := client.GetSecret(ctx, key)
+			require.NoError(t, err, "the committed record still reads")
+			require.Equal(t, "x", string(pt))
+		}
+	}
+}
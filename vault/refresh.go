@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RefreshOptions configures background refresh of cached plaintexts (see
+// WithBackgroundRefresh).
+type RefreshOptions struct {
+	// RefreshAhead starts an asynchronous refresh once a cached entry is
+	// within RefreshAhead of its TTL. Zero means a fifth of the TTL.
+	RefreshAhead time.Duration
+	// StaleFor lets an entry whose TTL has passed be served for up to this
+	// long while it is refreshed in the background. Zero disables stale
+	// serving; the entry then expires at its TTL as usual.
+	StaleFor time.Duration
+	// OnRefreshError, if set, is called from the refresh goroutine with the
+	// key and the *Error of every failed refresh.
+	OnRefreshError func(key string, err error)
+}
+
+// WithBackgroundRefresh keeps hot secrets off the repository/KMS/store
+// latency path. A cache hit that is close to expiry, or past it but within
+// opts.StaleFor, returns the cached plaintext immediately and refreshes the
+// entry asynchronously; at most one refresh per key runs at a time.
+//
+// When a refresh fails the last good plaintext keeps being served until the
+// stale window closes, and the failure goes to opts.OnRefreshError. A
+// refresh that finds the record gone (ErrNotFound) or no longer active
+// evicts the entry instead, so deletions and suspensions take effect without
+// waiting for the stale window.
+//
+// Refreshes run under the Client's own context and are not authorized
+// against any caller; every cache hit is still authorized. Call Client.Close
+// to stop them.
+func WithBackgroundRefresh(opts RefreshOptions) ClientOption {
+	return func(c *Client) { c.refresh = &opts }
+}
+
+// startRefresh finalizes refresh settings once the TTL is known.
+func (c *Client) startRefresh() {
+	if c.refresh == nil {
+		return
+	}
+	if c.refresh.RefreshAhead <= 0 || c.refresh.RefreshAhead > c.ttl {
+		c.refresh.RefreshAhead = c.ttl / 5
+	}
+	c.cacheOpts.TTL = c.ttl + c.refresh.StaleFor
+	c.bgCtx, c.bgCancel = context.WithCancel(context.Background())
+}
+
+// maybeRefresh starts a background refresh of key when hit is due for one.
+func (c *Client) maybeRefresh(key string, hit *cachedSecret) {
+	if c.refresh == nil || time.Since(hit.fetched) < c.ttl-c.refresh.RefreshAhead {
+		return
+	}
+	if _, busy := c.refreshing.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+	c.bgMu.Lock()
+	defer c.bgMu.Unlock()
+	if c.bgCtx.Err() != nil {
+		c.refreshing.Delete(key)
+		return
+	}
+	c.bgWG.Add(1)
+	go func() {
+		defer c.bgWG.Done()
+		defer c.refreshing.Delete(key)
+		c.refreshKey(c.bgCtx, key, hit.rec)
+	}()
+}
+
+// refreshKey reloads key bypassing the repository and store caches, and
+// re-caches its plaintext.
+func (c *Client) refreshKey(ctx context.Context, key string, prev *SecretRecord) {
+	const op = "Client.refresh"
+	if inv, ok := c.repo.(KeyInvalidator); ok {
+		inv.Invalidate(key)
+	}
+	rec, err := c.load(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.plaintextCache.Delete(key)
+		}
+		c.refreshFailed(key, withKey(err, op, key, prev.Store))
+		return
+	}
+	if err := statusErr(rec.Status, false); err != nil {
+		c.plaintextCache.Delete(key)
+		c.refreshFailed(key, &Error{Op: op, Key: key, Store: rec.Store, Err: err})
+		return
+	}
+	if err := c.checkKey(key, rec); err != nil {
+		c.plaintextCache.Delete(key)
+		c.refreshFailed(key, withKey(err, op, key, rec.Store))
+		return
+	}
+	cs, err := c.store(rec.Store)
+	if err == nil {
+		if inv, ok := cs.(KeyInvalidator); ok {
+			inv.Invalidate(key)
+		}
+		var pt []byte
+		pt, err = c.opens.Do(ctx, key+"|"+rec.Version, func(ctx context.Context) ([]byte, error) {
+			return c.open(ctx, cs, rec, true)
+		})
+		clear(pt) // cached by open; this copy is not needed
+	}
+	if err != nil {
+		c.refreshFailed(key, withKey(err, op, key, rec.Store))
+	}
+}
+
+func (c *Client) refreshFailed(key string, err error) {
+	if c.refresh.OnRefreshError != nil {
+		c.refresh.OnRefreshError(key, err)
+	}
+}
@@ -0,0 +1,194 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// flakyRepo counts GetSecret calls and fails them while err is set.
+type flakyRepo struct {
+	*vault.InMemoryRepo
+	calls atomic.Int32
+	mu    sync.Mutex
+	err   error
+}
+
+func (r *flakyRepo) GetSecret(ctx context.Context, key string) (*vault.SecretRecord, error) {
+	r.calls.Add(1)
+	r.mu.Lock()
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return r.InMemoryRepo.GetSecret(ctx, key)
+}
+
+func (r *flakyRepo) setErr(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+type refreshFixture struct {
+	repo     *flakyRepo
+	key      string
+	tenantID uuid.UUID
+	dek      []byte
+}
+
+func newRefreshFixture(t *testing.T) *refreshFixture {
+	t.Helper()
+	f := &refreshFixture{repo: &flakyRepo{InMemoryRepo: vault.NewInMemoryRepo()}, tenantID: uuid.New(), dek: make([]byte, 32)}
+	f.key = vault.MakeKey(uuid.New(), f.tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	_, _ = rand.Read(f.dek)
+	f.put(t, "v1", "first", vault.StatusActive)
+	return f
+}
+
+func (f *refreshFixture) put(t *testing.T, version, plaintext string, status vault.Status) {
+	t.Helper()
+	aad, _ := vault.MakeAADAndEncCtx(f.tenantID, f.key)
+	iv, ct, tag, err := fakes.EncryptWithDEK(f.dek, []byte(plaintext), aad)
+	require.NoError(t, err)
+	f.repo.Put(&vault.SecretRecord{
+		ID: keySecretID(t, f.key), TenantID: f.tenantID, Key: f.key, Store: vault.StoreDSVault, Status: status, Version: version,
+		Value: ct, IV: iv, Tag: tag, WrappedDEK: "V1JBUFBFRA==",
+	})
+}
+
+func (f *refreshFixture) client(ttl time.Duration, opts vault.RefreshOptions) *vault.Client {
+	kmsProv := vault.NewKMSProvider(&fakes.KMS{Plaintext: f.dek}, 16, time.Minute)
+	return vault.NewClient(f.repo, kmsProv, nil, ttl, vault.WithBackgroundRefresh(opts), openACL)
+}
+
+func TestClient_BackgroundRefresh_RefreshesAhead(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newRefreshFixture(t)
+	client := f.client(200*time.Millisecond, vault.RefreshOptions{RefreshAhead: 150 * time.Millisecond})
+	defer client.Close()
+
+	pt, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), pt)
+
+	f.put(t, "v2", "second", vault.StatusActive)
+	time.Sleep(80 * time.Millisecond) // inside the refresh-ahead window
+
+	pt, err = client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), pt, "served from cache while refreshing")
+
+	require.Eventually(t, func() bool {
+		pt, err := client.GetSecret(ctx, f.key)
+		return err == nil && string(pt) == "second"
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(2), f.repo.calls.Load(), "no caller paid for a miss")
+}
+
+func TestClient_BackgroundRefresh_ServesStaleOnFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newRefreshFixture(t)
+
+	var mu sync.Mutex
+	var hookKeys []string
+	var hookErrs []error
+	client := f.client(50*time.Millisecond, vault.RefreshOptions{
+		StaleFor: 300 * time.Millisecond,
+		OnRefreshError: func(key string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			hookKeys = append(hookKeys, key)
+			hookErrs = append(hookErrs, err)
+		},
+	})
+	defer client.Close()
+
+	_, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+
+	outage := errors.New("postgres is down")
+	f.repo.setErr(outage)
+	time.Sleep(70 * time.Millisecond) // past the TTL, inside the stale window
+
+	pt, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), pt)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(hookErrs) > 0
+	}, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	require.Equal(t, f.key, hookKeys[0])
+	var ve *vault.Error
+	require.ErrorAs(t, hookErrs[0], &ve)
+	require.ErrorIs(t, hookErrs[0], outage)
+	require.Equal(t, f.key, ve.Key)
+	mu.Unlock()
+
+	// Once the stale window closes, the outage reaches the caller.
+	time.Sleep(350 * time.Millisecond)
+	_, err = client.GetSecret(ctx, f.key)
+	require.ErrorIs(t, err, outage)
+}
+
+func TestClient_BackgroundRefresh_EvictsInactive(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newRefreshFixture(t)
+	client := f.client(50*time.Millisecond, vault.RefreshOptions{StaleFor: time.Minute})
+	defer client.Close()
+
+	_, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+
+	f.put(t, "v1", "first", vault.StatusSuspended)
+	time.Sleep(70 * time.Millisecond)
+
+	// The stale hit triggers a refresh that sees the suspension.
+	_, _ = client.GetSecret(ctx, f.key)
+	require.Eventually(t, func() bool {
+		_, err := client.GetSecret(ctx, f.key)
+		return errors.Is(err, vault.ErrSecretSuspended)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestClient_Close_StopsRefresh(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newRefreshFixture(t)
+	client := f.client(50*time.Millisecond, vault.RefreshOptions{StaleFor: time.Minute})
+
+	_, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Close())
+
+	// Close wiped the cache, so this read goes to the repository...
+	pt, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), pt)
+	require.Equal(t, int32(2), f.repo.calls.Load())
+
+	// ...and the stale hit after it starts no refresh.
+	time.Sleep(70 * time.Millisecond)
+	_, err = client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(2), f.repo.calls.Load())
+}
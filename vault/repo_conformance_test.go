@@ -0,0 +1,260 @@
+package vault_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grasp-labs/ds-go-commonmodels/v2/commonmodels/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// Every repository must pass the same suite, so code written against the
+// in-memory repo in tests behaves the same against Postgres. The pgx
+// repository needs a real Postgres (DSVAULT_PG_DSN).
+var (
+	_ vault.SecretRepository = (*vault.InMemoryRepo)(nil)
+	_ vault.SecretRepository = (*vault.PostgresSecretRepository)(nil)
+	_ vault.SecretRepository = (*vault.PgxSecretRepository)(nil)
+)
+
+func TestSecretRepository_Conformance(t *testing.T) {
+	t.Parallel()
+	impls := map[string]func(t *testing.T) vault.SecretRepository{
+		"memory": func(t *testing.T) vault.SecretRepository { return vault.NewInMemoryRepo() },
+		"postgres": func(t *testing.T) vault.SecretRepository {
+			db := fakes.NewDB(t, "file:"+t.Name()+"?mode=memory&cache=shared")
+			repo, err := vault.NewGormSecretRepository(nil, "secret_records", vault.WithExistingDB(db), vault.WithVersionHistory())
+			require.NoError(t, err)
+			return repo
+		},
+	}
+	if os.Getenv("DSVAULT_PG_DSN") != "" {
+		impls["pgx"] = func(t *testing.T) vault.SecretRepository {
+			dsn, table, _ := pgTable(t)
+			return newPgxRepo(t, dsn, table, vault.WithVersionHistory())
+		}
+	}
+	for name, newRepo := range impls {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testRepositoryConformance(t, newRepo)
+		})
+	}
+}
+
+func conformanceRecord(tenantID uuid.UUID, key string) *vault.SecretRecord {
+	return &vault.SecretRecord{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		Key:        key,
+		Name:       "db-password",
+		Version:    "v1",
+		Store:      vault.StoreDSVault,
+		Status:     vault.StatusActive,
+		AADScheme:  vault.AADSchemeV2,
+		Commitment: "Y29tbWl0",
+		Tags:       types.JSONB[map[string]string]{Data: map[string]string{"team": "data", "env": "dev"}},
+		ModifiedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func listKeys(t *testing.T, repo vault.SecretRepository, opts vault.ListOptions) []string {
+	t.Helper()
+	page, err := repo.ListSecrets(context.Background(), opts)
+	require.NoError(t, err)
+	keys := make([]string, len(page.Records))
+	for i, rec := range page.Records {
+		keys[i] = rec.Key
+	}
+	return keys
+}
+
+func testRepositoryConformance(t *testing.T, newRepo func(t *testing.T) vault.SecretRepository) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+		rec := conformanceRecord(uuid.New(), "svc/a")
+		require.NoError(t, repo.CreateSecret(ctx, rec))
+		require.ErrorIs(t, repo.CreateSecret(ctx, rec), vault.ErrAlreadyExists)
+
+		got, err := repo.GetSecret(ctx, "svc/a")
+		require.NoError(t, err)
+		require.Equal(t, rec.ID, got.ID)
+		require.Equal(t, "data", got.Tags.Data["team"])
+		require.Equal(t, vault.AADSchemeV2, got.AADScheme)
+		require.Equal(t, "Y29tbWl0", got.Commitment)
+
+		_, err = repo.GetSecret(ctx, "svc/missing")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+	})
+
+	t.Run("update preconditions", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateSecret(ctx, conformanceRecord(uuid.New(), "svc/a")))
+		cur, err := repo.GetSecret(ctx, "svc/a")
+		require.NoError(t, err)
+
+		next := *cur
+		next.Version = "v2"
+		next.ModifiedAt = cur.ModifiedAt.Add(time.Second)
+		require.NoError(t, repo.UpdateSecret(ctx, &next, vault.Precondition{Version: "v1", ModifiedAt: cur.ModifiedAt}))
+
+		stale := next
+		stale.Version = "v3"
+		require.ErrorIs(t, repo.UpdateSecret(ctx, &stale, vault.Precondition{Version: "v1"}), vault.ErrConflict)
+		require.ErrorIs(t, repo.UpdateSecret(ctx, &stale, vault.Precondition{ModifiedAt: cur.ModifiedAt}), vault.ErrConflict)
+		require.NoError(t, repo.UpdateSecret(ctx, &stale, vault.Precondition{ModifiedAt: next.ModifiedAt}))
+
+		got, err := repo.GetSecret(ctx, "svc/a")
+		require.NoError(t, err)
+		require.Equal(t, "v3", got.Version)
+
+		missing := conformanceRecord(uuid.New(), "svc/missing")
+		require.ErrorIs(t, repo.UpdateSecret(ctx, missing, vault.Precondition{}), vault.ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateSecret(ctx, conformanceRecord(uuid.New(), "svc/a")))
+		require.NoError(t, repo.CreateSecret(ctx, conformanceRecord(uuid.New(), "svc/b")))
+
+		require.NoError(t, repo.SoftDeleteSecret(ctx, "svc/a"))
+		got, err := repo.GetSecret(ctx, "svc/a")
+		require.NoError(t, err)
+		require.Equal(t, vault.StatusDeleted, got.Status)
+		require.True(t, got.ModifiedAt.After(conformanceRecord(uuid.Nil, "").ModifiedAt))
+
+		require.NoError(t, repo.HardDeleteSecret(ctx, "svc/b"))
+		_, err = repo.GetSecret(ctx, "svc/b")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+
+		require.ErrorIs(t, repo.SoftDeleteSecret(ctx, "svc/b"), vault.ErrNotFound)
+		require.ErrorIs(t, repo.HardDeleteSecret(ctx, "svc/b"), vault.ErrNotFound)
+	})
+
+	t.Run("list filters", func(t *testing.T) {
+		repo := newRepo(t)
+		tenantA, tenantB := uuid.New(), uuid.New()
+
+		a := conformanceRecord(tenantA, "a/1")
+		b := conformanceRecord(tenantA, "a/2")
+		b.Store = vault.StoreAWSSSM
+		b.Name = "api-token"
+		c := conformanceRecord(tenantA, "a_3") // '_' must not act as a LIKE wildcard
+		c.Tags.Data = map[string]string{"team": "web"}
+		d := conformanceRecord(tenantB, "b/1")
+		d.Status = vault.StatusSuspended
+		for _, rec := range []*vault.SecretRecord{a, b, c, d} {
+			require.NoError(t, repo.CreateSecret(ctx, rec))
+		}
+
+		require.Equal(t, []string{"a/1", "a/2", "a_3", "b/1"}, listKeys(t, repo, vault.ListOptions{}))
+		require.Equal(t, []string{"a/1", "a/2", "a_3"}, listKeys(t, repo, vault.ListOptions{TenantID: tenantA}))
+		require.Equal(t, []string{"a/2"}, listKeys(t, repo, vault.ListOptions{Store: vault.StoreAWSSSM}))
+		require.Equal(t, []string{"b/1"}, listKeys(t, repo, vault.ListOptions{Status: vault.StatusSuspended}))
+		require.Equal(t, []string{"a/2"}, listKeys(t, repo, vault.ListOptions{Name: "api-token"}))
+		require.Equal(t, []string{"a/1", "a/2"}, listKeys(t, repo, vault.ListOptions{KeyPrefix: "a/"}))
+		require.Equal(t, []string{"a/1", "a/2", "b/1"}, listKeys(t, repo, vault.ListOptions{Tags: map[string]string{"team": "data"}}))
+		require.Equal(t, []string{"a_3"}, listKeys(t, repo, vault.ListOptions{Tags: map[string]string{"team": "web"}}))
+		require.Empty(t, listKeys(t, repo, vault.ListOptions{Tags: map[string]string{"team": "web", "env": "dev"}}))
+		require.Equal(t, []string{"a/1"}, listKeys(t, repo, vault.ListOptions{
+			TenantID: tenantA, Store: vault.StoreDSVault, Status: vault.StatusActive, Name: "db-password",
+			KeyPrefix: "a/", Tags: map[string]string{"env": "dev"},
+		}))
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		repo := newRepo(t)
+		require.Implements(t, (*vault.SecretVersioner)(nil), repo)
+		v := repo.(vault.SecretVersioner)
+
+		rec := conformanceRecord(uuid.New(), "svc/a")
+		rec.IV, rec.WrappedDEK = "iv1", "dek1"
+		require.NoError(t, repo.CreateSecret(ctx, rec))
+		for i, ver := range []string{"v2", "v3"} {
+			next := *rec
+			next.Version, next.IV, next.WrappedDEK = ver, fmt.Sprintf("iv%d", i+2), fmt.Sprintf("dek%d", i+2)
+			require.NoError(t, repo.UpdateSecret(ctx, &next, vault.Precondition{Version: rec.Version}))
+			rec = &next
+		}
+		// Updates that keep the version replace its entry instead of adding one.
+		same := *rec
+		edited := "edited"
+		same.Description = &edited
+		require.NoError(t, repo.UpdateSecret(ctx, &same, vault.Precondition{Version: "v3"}))
+
+		hist, err := v.ListVersions(ctx, "svc/a")
+		require.NoError(t, err)
+		require.Len(t, hist, 3)
+		for i, h := range hist {
+			require.Equal(t, fmt.Sprintf("v%d", i+1), h.Version)
+			require.Equal(t, fmt.Sprintf("iv%d", i+1), h.IV)
+			require.Equal(t, fmt.Sprintf("dek%d", i+1), h.WrappedDEK)
+		}
+		require.Equal(t, "edited", *hist[2].Description)
+
+		for version, want := range map[string]string{"v1": "iv1", "v2": "iv2", "v3": "iv3", vault.VersionCurrent: "iv3", vault.VersionPrevious: "iv2"} {
+			got, err := v.GetSecretVersion(ctx, "svc/a", version)
+			require.NoError(t, err, version)
+			require.Equal(t, want, got.IV, version)
+		}
+		_, err = v.GetSecretVersion(ctx, "svc/a", "v9")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+		_, err = v.GetSecretVersion(ctx, "svc/missing", vault.VersionPrevious)
+		require.ErrorIs(t, err, vault.ErrNotFound)
+		_, err = v.ListVersions(ctx, "svc/missing")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+
+		// A single version has no previous one.
+		require.NoError(t, repo.CreateSecret(ctx, conformanceRecord(uuid.New(), "svc/b")))
+		_, err = v.GetSecretVersion(ctx, "svc/b", vault.VersionPrevious)
+		require.ErrorIs(t, err, vault.ErrNotFound)
+
+		// Soft deletes mark the current version; hard deletes drop the history.
+		require.NoError(t, repo.SoftDeleteSecret(ctx, "svc/a"))
+		hist, err = v.ListVersions(ctx, "svc/a")
+		require.NoError(t, err)
+		require.Equal(t, vault.StatusActive, hist[1].Status)
+		require.Equal(t, vault.StatusDeleted, hist[2].Status)
+		require.NoError(t, repo.HardDeleteSecret(ctx, "svc/a"))
+		_, err = v.ListVersions(ctx, "svc/a")
+		require.ErrorIs(t, err, vault.ErrNotFound)
+	})
+
+	t.Run("list pagination", func(t *testing.T) {
+		repo := newRepo(t)
+		tenantID := uuid.New()
+		var want []string
+		for i := range 7 {
+			key := fmt.Sprintf("svc/%02d", i)
+			want = append(want, key)
+			require.NoError(t, repo.CreateSecret(ctx, conformanceRecord(tenantID, key)))
+		}
+		require.NoError(t, repo.CreateSecret(ctx, conformanceRecord(uuid.New(), "svc/other")))
+
+		var got []string
+		opts := vault.ListOptions{TenantID: tenantID, Limit: 3}
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 3)
+			page, err := repo.ListSecrets(ctx, opts)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.Records), 3)
+			for _, rec := range page.Records {
+				got = append(got, rec.Key)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		require.Equal(t, want, got)
+	})
+}
@@ -0,0 +1,213 @@
+package vault
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// RepositoryOption customizes a PostgresSecretRepository built by
+// NewPostgresSecretRepository or NewGormSecretRepository, or a
+// PgxSecretRepository. The gorm-specific options (WithGormConfig,
+// WithExistingDB, WithLogger) do not apply to PgxSecretRepository.
+type RepositoryOption func(*repoConfig)
+
+type repoConfig struct {
+	cacheSize int
+	cacheTTL  time.Duration
+	noCache   bool
+	gormCfg   *gorm.Config
+	db        *gorm.DB
+	logger    logger.Interface
+	columns   map[string]string
+	history   bool
+}
+
+// WithCache sets the size and TTL of the record cache. The default is 4096
+// records for one minute.
+func WithCache(size int, ttl time.Duration) RepositoryOption {
+	return func(c *repoConfig) { c.cacheSize, c.cacheTTL, c.noCache = size, ttl, false }
+}
+
+// WithoutCache disables the record cache: every GetSecret queries the
+// database. The negative cache (SetNegativeCacheTTL) is unaffected.
+func WithoutCache() RepositoryOption {
+	return func(c *repoConfig) { c.noCache = true }
+}
+
+// WithGormConfig opens the database with cfg instead of the default config.
+// TranslateError is always turned on, since the repository relies on it to
+// detect duplicate keys. Ignored with WithExistingDB.
+func WithGormConfig(cfg *gorm.Config) RepositoryOption {
+	return func(c *repoConfig) { c.gormCfg = cfg }
+}
+
+// WithExistingDB makes the repository use db, and its connection pool,
+// instead of opening its own; the dialector passed to the constructor is
+// ignored. Open db with TranslateError: true so that concurrent creates of
+// the same key map to ErrAlreadyExists.
+func WithExistingDB(db *gorm.DB) RepositoryOption {
+	return func(c *repoConfig) { c.db = db }
+}
+
+// WithLogger sets the gorm logger used for the repository's queries. With
+// WithExistingDB it applies to the repository's session only.
+func WithLogger(l logger.Interface) RepositoryOption {
+	return func(c *repoConfig) { c.logger = l }
+}
+
+// WithColumnMapping maps SecretRecord columns to the names used by an
+// existing table, e.g. {"key": "secret_path", "wrapped_dek": "dek_blob"}.
+// Keys are the default snake_case column names (as created by Migrate);
+// unmapped columns keep their default name.
+func WithColumnMapping(columns map[string]string) RepositoryOption {
+	return func(c *repoConfig) { c.columns = columns }
+}
+
+// WithVersionHistory makes the repository maintain the <table>_versions
+// history that GetSecretVersion and ListVersions read. The table is created
+// by Migrate (version 3); run it before enabling history, since every write
+// also goes to that table. Without it, GetSecretVersion and ListVersions fail
+// with ErrNotSupported, except for the VersionCurrent alias.
+func WithVersionHistory() RepositoryOption {
+	return func(c *repoConfig) { c.history = true }
+}
+
+// newRepoConfig applies opts on top of the defaults and validates the table
+// name and column mapping.
+func newRepoConfig(op, table string, opts []RepositoryOption) (repoConfig, error) {
+	if !validTable.MatchString(table) {
+		return repoConfig{}, errorf(op, "", ErrInvalidArgument, "invalid table name: %s", table)
+	}
+	cfg := repoConfig{cacheSize: 4096, cacheTTL: time.Minute}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for from, to := range cfg.columns {
+		if _, ok := recordColumns()[from]; !ok {
+			return repoConfig{}, errorf(op, "", ErrInvalidArgument, "unknown column in mapping: %s", from)
+		}
+		if !validIdent.MatchString(to) {
+			return repoConfig{}, errorf(op, "", ErrInvalidArgument, "invalid column name: %s", to)
+		}
+	}
+	return cfg, nil
+}
+
+// newRepository applies opts on top of the defaults and connects.
+func newRepository(dialector gorm.Dialector, table string, opts []RepositoryOption) (*PostgresSecretRepository, error) {
+	const op = "repo.Open"
+	cfg, err := newRepoConfig(op, table, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	db := cfg.db
+	switch {
+	case db != nil && cfg.logger != nil:
+		db = db.Session(&gorm.Session{Logger: cfg.logger})
+	case db == nil:
+		gcfg := &gorm.Config{}
+		if cfg.gormCfg != nil {
+			cp := *cfg.gormCfg
+			gcfg = &cp
+		}
+		gcfg.TranslateError = true
+		if cfg.logger != nil {
+			gcfg.Logger = cfg.logger
+		}
+		if db, err = gorm.Open(dialector, gcfg); err != nil {
+			return nil, &Error{Op: op, Err: err}
+		}
+	}
+
+	r := &PostgresSecretRepository{db: db, table: table, columns: cfg.columns}
+	if cfg.history {
+		r.versions = table + "_versions"
+	}
+	if !cfg.noCache {
+		r.cache = NewTTLCache[*SecretRecord](cfg.cacheSize, cfg.cacheTTL)
+	}
+	if len(cfg.columns) > 0 {
+		for _, f := range recordFields() {
+			r.selects = append(r.selects, r.col(f.DBName)+" AS "+f.DBName)
+		}
+	}
+	return r, nil
+}
+
+var (
+	recordSchemaOnce sync.Once
+	recordSchema     *schema.Schema
+)
+
+// recordFields returns the persisted fields of SecretRecord.
+func recordFields() []*schema.Field {
+	recordSchemaOnce.Do(func() {
+		s, err := schema.Parse(&SecretRecord{}, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			panic("vault: parse SecretRecord schema: " + err.Error())
+		}
+		recordSchema = s
+	})
+	var out []*schema.Field
+	for _, f := range recordSchema.Fields {
+		if f.DBName != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// recordColumns returns the set of default column names.
+func recordColumns() map[string]bool {
+	cols := map[string]bool{}
+	for _, f := range recordFields() {
+		cols[f.DBName] = true
+	}
+	return cols
+}
+
+// col returns the table's name for the default column name.
+func (r *PostgresSecretRepository) col(name string) string {
+	if c, ok := r.columns[name]; ok {
+		return c
+	}
+	return name
+}
+
+// query starts a statement on the repository table that reads SecretRecords,
+// aliasing mapped columns back to their default names.
+func (r *PostgresSecretRepository) query(ctx context.Context) *gorm.DB {
+	tx := r.db.WithContext(ctx).Table(r.table)
+	if len(r.selects) > 0 {
+		tx = tx.Select(r.selects)
+	}
+	return tx
+}
+
+// values returns what to pass to Create/Updates for rec: rec itself, or a
+// column map when columns are mapped.
+func (r *PostgresSecretRepository) values(rec *SecretRecord) any {
+	if len(r.columns) == 0 {
+		return rec
+	}
+	return r.columnValues(rec)
+}
+
+// columnValues maps the table's column names to rec's values.
+func (r *PostgresSecretRepository) columnValues(rec *SecretRecord) map[string]any {
+	rv := reflect.ValueOf(rec).Elem()
+	fields := recordFields()
+	m := make(map[string]any, len(fields))
+	for _, f := range fields {
+		v, _ := f.ValueOf(context.Background(), rv)
+		m[r.col(f.DBName)] = v
+	}
+	return m
+}
@@ -0,0 +1,127 @@
+package vault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestRepositoryOptions_Cache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db := fakes.NewDB(t, dsn)
+	require.NoError(t, db.Create(&vault.SecretRecord{ID: uuid.New(), Key: "svc/a", Value: "v1"}).Error)
+
+	cached, err := vault.NewGormSecretRepository(nil, "secret_records", vault.WithExistingDB(db))
+	require.NoError(t, err)
+	uncached, err := vault.NewGormSecretRepository(nil, "secret_records", vault.WithExistingDB(db), vault.WithoutCache())
+	require.NoError(t, err)
+	short, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records",
+		vault.WithCache(16, 30*time.Millisecond),
+		vault.WithGormConfig(&gorm.Config{}),
+		vault.WithLogger(logger.Discard))
+	require.NoError(t, err)
+
+	for _, r := range []*vault.PostgresSecretRepository{cached, uncached, short} {
+		got, err := r.GetSecret(ctx, "svc/a")
+		require.NoError(t, err)
+		require.Equal(t, "v1", got.Value)
+	}
+
+	require.NoError(t, db.Table("secret_records").Where("key = ?", "svc/a").Update("value", "v2").Error)
+
+	got, err := cached.GetSecret(ctx, "svc/a")
+	require.NoError(t, err)
+	require.Equal(t, "v1", got.Value, "default cache keeps the record for a minute")
+
+	got, err = uncached.GetSecret(ctx, "svc/a")
+	require.NoError(t, err)
+	require.Equal(t, "v2", got.Value)
+
+	require.Eventually(t, func() bool {
+		got, err := short.GetSecret(ctx, "svc/a")
+		return err == nil && got.Value == "v2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRepositoryOptions_ColumnMapping(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db := fakes.NewDB(t, dsn)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	mapping := vault.WithColumnMapping(map[string]string{"key": "secret_path", "wrapped_dek": "dek_blob"})
+	require.NoError(t, vault.Migrate(ctx, sqlDB, "legacy_secrets", mapping))
+	for _, table := range []string{"legacy_secrets", "legacy_secrets_versions"} {
+		require.NoError(t, db.Exec("SELECT secret_path, dek_blob FROM "+table).Error, "Migrate applies the mapping to "+table)
+	}
+
+	repo, err := vault.NewGormSecretRepository(nil, "legacy_secrets",
+		vault.WithExistingDB(db), vault.WithoutCache(), vault.WithVersionHistory(), mapping)
+	require.NoError(t, err)
+
+	tenantID := uuid.New()
+	rec := &vault.SecretRecord{ID: uuid.New(), TenantID: tenantID, Key: "svc/b", Version: "v1", WrappedDEK: "old", Status: vault.StatusActive}
+	require.NoError(t, repo.CreateSecret(ctx, rec))
+	require.ErrorIs(t, repo.CreateSecret(ctx, rec), vault.ErrAlreadyExists)
+
+	var raw struct{ SecretPath, DekBlob string }
+	require.NoError(t, db.Table("legacy_secrets").Select("secret_path, dek_blob").Take(&raw).Error)
+	require.Equal(t, "svc/b", raw.SecretPath)
+	require.Equal(t, "old", raw.DekBlob)
+
+	got, err := repo.GetSecret(ctx, "svc/b")
+	require.NoError(t, err)
+	require.Equal(t, "svc/b", got.Key)
+	require.Equal(t, "old", got.WrappedDEK)
+	require.Equal(t, tenantID, got.TenantID)
+
+	next := *got
+	next.Version = "v2"
+	require.NoError(t, repo.UpdateSecret(ctx, &next, vault.Precondition{Version: "v1"}))
+	require.ErrorIs(t, repo.UpdateSecret(ctx, &next, vault.Precondition{Version: "v1"}), vault.ErrConflict)
+
+	require.NoError(t, repo.UpdateWrappedDEK(ctx, "svc/b", "old", "new", "alias/next"))
+
+	page, err := repo.ListSecrets(ctx, vault.ListOptions{KeyPrefix: "svc/"})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	require.Equal(t, "v2", page.Records[0].Version)
+	require.Equal(t, "new", page.Records[0].WrappedDEK)
+	require.Equal(t, "alias/next", page.Records[0].KEKKeyID)
+
+	hist, err := repo.ListVersions(ctx, "svc/b")
+	require.NoError(t, err)
+	require.Len(t, hist, 2)
+	require.Equal(t, "svc/b", hist[0].Key)
+	require.Equal(t, "v1", hist[0].Version)
+	require.Equal(t, "v2", hist[1].Version)
+	require.Equal(t, "new", hist[0].WrappedDEK, "rewrap covers every version sharing the DEK")
+	prev, err := repo.GetSecretVersion(ctx, "svc/b", vault.VersionPrevious)
+	require.NoError(t, err)
+	require.Equal(t, "v1", prev.Version)
+
+	require.NoError(t, vault.MigrateDown(ctx, sqlDB, "legacy_secrets", 3, mapping))
+	require.Error(t, db.Exec("SELECT aad_scheme FROM legacy_secrets").Error, "migrations 4 and 5 reverted")
+	require.NoError(t, vault.Migrate(ctx, sqlDB, "legacy_secrets", mapping))
+
+	_, err = vault.NewGormSecretRepository(nil, "legacy_secrets", vault.WithExistingDB(db),
+		vault.WithColumnMapping(map[string]string{"no_such_column": "x"}))
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	_, err = vault.NewGormSecretRepository(nil, "legacy_secrets", vault.WithExistingDB(db),
+		vault.WithColumnMapping(map[string]string{"key": "key; DROP TABLE x"}))
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	require.ErrorIs(t, vault.Migrate(ctx, sqlDB, "legacy_secrets",
+		vault.WithColumnMapping(map[string]string{"key": "key; DROP TABLE x"})), vault.ErrInvalidArgument)
+}
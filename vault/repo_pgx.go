@@ -0,0 +1,589 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgxColumns lists the SecretRecord columns in the order scanRecord and
+// recordArgs use. Names are the defaults; WithColumnMapping renames them.
+var pgxColumns = []string{
+	"id", "tenant_id", "owner_id", "issuer", "name", "version", "description", "status",
+	"metadata", "tags", "created_at", "created_by", "modified_at", "modified_by",
+	"key", "store", "value", "acl", "iv", "tag", "wrapped_dek", "kek_key_id", "dek_alg", "kek_alg",
+	"aad_scheme", "commitment", "store_version",
+}
+
+// Prepared statement names; each connection prepares them on first use.
+const (
+	pgxStmtGet     = "get"
+	pgxStmtExists  = "exists"
+	pgxStmtCreate  = "create"
+	pgxStmtUpdate  = "update"
+	pgxStmtSoftDel = "soft_delete"
+	pgxStmtHardDel = "hard_delete"
+	pgxStmtRewrap  = "rewrap"
+
+	pgxStmtVerGet     = "version_get"
+	pgxStmtVerPrev    = "version_previous"
+	pgxStmtVerList    = "version_list"
+	pgxStmtVerUpdate  = "version_update"
+	pgxStmtVerInsert  = "version_insert"
+	pgxStmtVerSoftDel = "version_soft_delete"
+	pgxStmtVerHardDel = "version_hard_delete"
+	pgxStmtVerRewrap  = "version_rewrap"
+	pgxStmtVerRewrap1 = "version_rewrap_one"
+)
+
+// PgxSecretRepository is a SecretRepository on a *pgxpool.Pool. It runs the
+// same queries as PostgresSecretRepository without GORM: fixed queries are
+// prepared once per connection, columns are listed explicitly, and the JSONB
+// Metadata, Tags and ACL columns are decoded here. Caching, notifications and
+// errors behave as in PostgresSecretRepository.
+type PgxSecretRepository struct {
+	pool   *pgxpool.Pool
+	table  string
+	cache  *TTLCache[*SecretRecord]
+	notify string // NOTIFY channel for writes; empty disables
+
+	negative *negativeCache // nil unless SetNegativeCacheTTL
+
+	columns map[string]string // default column name -> table column (WithColumnMapping)
+	selects string            // select list, aliased back to default names
+	prefix  string            // prepared statement name prefix for table
+	stmts   map[string]string // prepared statement name (sans prefix) -> SQL
+
+	versions string // version history table; empty without WithVersionHistory
+}
+
+// NewPgxSecretRepository reads and writes records in table through pool.
+// It accepts the same RepositoryOptions as NewPostgresSecretRepository, except
+// the gorm-specific ones, which are ignored.
+func NewPgxSecretRepository(pool *pgxpool.Pool, table string, opts ...RepositoryOption) (*PgxSecretRepository, error) {
+	cfg, err := newRepoConfig("repo.Open", table, opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &PgxSecretRepository{pool: pool, table: table, columns: cfg.columns}
+	if cfg.history {
+		r.versions = table + "_versions"
+	}
+	if !cfg.noCache {
+		r.cache = NewTTLCache[*SecretRecord](cfg.cacheSize, cfg.cacheTTL)
+	}
+
+	sel := make([]string, len(pgxColumns))
+	cols := make([]string, len(pgxColumns))
+	params := make([]string, len(pgxColumns))
+	sets := make([]string, len(pgxColumns))
+	for i, c := range pgxColumns {
+		cols[i] = r.col(c)
+		sel[i] = cols[i]
+		if cols[i] != c {
+			sel[i] += " AS " + c
+		}
+		params[i] = fmt.Sprintf("$%d", i+1)
+		sets[i] = cols[i] + " = " + params[i]
+	}
+	r.selects = strings.Join(sel, ", ")
+	key, keyParam := r.col("key"), params[slices.Index(pgxColumns, "key")]
+	version, versionParam := r.col("version"), params[slices.Index(pgxColumns, "version")]
+	n := len(pgxColumns)
+	r.prefix = "dsvault_" + strings.ReplaceAll(table, ".", "_") + "_"
+	r.stmts = map[string]string{
+		pgxStmtGet:    "SELECT " + r.selects + " FROM " + table + " WHERE " + key + " = $1 LIMIT 1",
+		pgxStmtExists: "SELECT EXISTS (SELECT 1 FROM " + table + " WHERE " + key + " = $1)",
+		pgxStmtCreate: "INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES (" + strings.Join(params, ", ") + ")",
+		pgxStmtUpdate: "UPDATE " + table + " SET " + strings.Join(sets, ", ") + " WHERE " + key + " = " + keyParam +
+			fmt.Sprintf(" AND ($%d = '' OR %s = $%d)", n+1, r.col("version"), n+1) +
+			fmt.Sprintf(" AND ($%d::timestamptz IS NULL OR %s = $%d)", n+2, r.col("modified_at"), n+2),
+		pgxStmtSoftDel: "UPDATE " + table + " SET " + r.col("status") + " = $2, " + r.col("modified_at") + " = $3 WHERE " + key + " = $1",
+		pgxStmtHardDel: "DELETE FROM " + table + " WHERE " + key + " = $1",
+		pgxStmtRewrap: "UPDATE " + table + " SET " + r.col("wrapped_dek") + " = $3, " + r.col("kek_key_id") + " = $4, " +
+			r.col("modified_at") + " = $5 WHERE " + key + " = $1 AND " + r.col("wrapped_dek") + " = $2",
+	}
+	if vt := r.versions; vt != "" {
+		for name, sql := range map[string]string{
+			pgxStmtVerGet:  "SELECT " + r.selects + " FROM " + vt + " WHERE " + key + " = $1 AND " + version + " = $2",
+			pgxStmtVerList: "SELECT " + r.selects + " FROM " + vt + " WHERE " + key + " = $1 ORDER BY seq",
+			pgxStmtVerPrev: "SELECT " + r.selects + " FROM " + vt + " WHERE " + key + " = $1 AND seq < (SELECT seq FROM " + vt +
+				" WHERE " + key + " = $1 AND " + version + " = $2) ORDER BY seq DESC LIMIT 1",
+			pgxStmtVerUpdate: "UPDATE " + vt + " SET " + strings.Join(sets, ", ") + " WHERE " + key + " = " + keyParam + " AND " + version + " = " + versionParam,
+			pgxStmtVerInsert: "INSERT INTO " + vt + " (seq, " + strings.Join(cols, ", ") + ") VALUES ((SELECT COALESCE(MAX(seq), 0) + 1 FROM " + vt +
+				" WHERE " + key + " = " + keyParam + "), " + strings.Join(params, ", ") + ")",
+			pgxStmtVerSoftDel: "UPDATE " + vt + " SET " + r.col("status") + " = $2, " + r.col("modified_at") + " = $3 WHERE " + key + " = $1 AND " +
+				version + " = (SELECT " + version + " FROM " + table + " WHERE " + key + " = $1)",
+			pgxStmtVerHardDel: "DELETE FROM " + vt + " WHERE " + key + " = $1",
+			pgxStmtVerRewrap: "UPDATE " + vt + " SET " + r.col("wrapped_dek") + " = $3, " + r.col("kek_key_id") + " = $4, " +
+				r.col("modified_at") + " = $5 WHERE " + key + " = $1 AND " + r.col("wrapped_dek") + " = $2",
+			pgxStmtVerRewrap1: "UPDATE " + vt + " SET " + r.col("wrapped_dek") + " = $4, " + r.col("kek_key_id") + " = $5, " +
+				r.col("modified_at") + " = $6 WHERE " + key + " = $1 AND " + version + " = $2 AND " + r.col("wrapped_dek") + " = $3",
+		} {
+			r.stmts[name] = sql
+		}
+	}
+	return r, nil
+}
+
+// SetNotifyChannel is PostgresSecretRepository.SetNotifyChannel.
+func (r *PgxSecretRepository) SetNotifyChannel(channel string) error {
+	if channel != "" && !validIdent.MatchString(channel) {
+		return errorf("repo.SetNotifyChannel", "", ErrInvalidArgument, "invalid channel name: %s", channel)
+	}
+	r.notify = channel
+	return nil
+}
+
+// SetNegativeCacheTTL is PostgresSecretRepository.SetNegativeCacheTTL.
+func (r *PgxSecretRepository) SetNegativeCacheTTL(ttl time.Duration) {
+	r.negative = newNegativeCache(ttl)
+}
+
+// NegativeCacheStats reports on the negative cache (see SetNegativeCacheTTL).
+func (r *PgxSecretRepository) NegativeCacheStats() NegativeCacheStats {
+	return r.negative.stats()
+}
+
+// PurgeNegativeCache forgets every key remembered as not found.
+func (r *PgxSecretRepository) PurgeNegativeCache() {
+	r.negative.purge()
+}
+
+// Invalidate drops key from the record and negative caches so the next
+// GetSecret reads the row again.
+func (r *PgxSecretRepository) Invalidate(key string) {
+	r.cache.Delete(key)
+	r.negative.forget(key)
+}
+
+// Purge drops every cached record and negative cache entry.
+func (r *PgxSecretRepository) Purge() {
+	r.cache.Clear()
+	r.negative.purge()
+}
+
+func (r *PgxSecretRepository) col(name string) string {
+	if c, ok := r.columns[name]; ok {
+		return c
+	}
+	return name
+}
+
+// stmt returns the prepared statement name for one of the pgxStmt constants.
+func (r *PgxSecretRepository) stmt(s string) string { return r.prefix + s }
+
+// withConn runs fn on a pooled connection on which stmts are prepared. pgx
+// skips the round trip for statements the connection already has.
+func (r *PgxSecretRepository) withConn(ctx context.Context, fn func(c *pgx.Conn) error, stmts ...string) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	for _, s := range stmts {
+		if _, err := conn.Conn().Prepare(ctx, r.stmt(s), r.stmts[s]); err != nil {
+			return err
+		}
+	}
+	return fn(conn.Conn())
+}
+
+// inTx runs fn in a transaction on a pooled connection with stmts prepared.
+func (r *PgxSecretRepository) inTx(ctx context.Context, fn func(tx pgx.Tx) error, stmts ...string) error {
+	return r.withConn(ctx, func(c *pgx.Conn) error {
+		return pgx.BeginFunc(ctx, c, fn)
+	}, stmts...)
+}
+
+// exec runs a prepared statement and, if it affected any row and version
+// history is on, histStmt with the same arguments in the same transaction.
+// It returns the row count of stmt.
+func (r *PgxSecretRepository) exec(ctx context.Context, stmt, histStmt string, args ...any) (int64, error) {
+	stmts := []string{stmt}
+	if r.versions != "" {
+		stmts = append(stmts, histStmt)
+	}
+	var n int64
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, r.stmt(stmt), args...)
+		if n = tag.RowsAffected(); err != nil || n == 0 || r.versions == "" {
+			return err
+		}
+		_, err = tx.Exec(ctx, r.stmt(histStmt), args...)
+		return err
+	}, stmts...)
+	return n, err
+}
+
+// writeVersion records rec (as recordArgs) in the version history: it
+// replaces the row for rec.Version, or appends one after the key's latest.
+func (r *PgxSecretRepository) writeVersion(ctx context.Context, tx pgx.Tx, args []any) error {
+	if r.versions == "" {
+		return nil
+	}
+	tag, err := tx.Exec(ctx, r.stmt(pgxStmtVerUpdate), args...)
+	if err != nil || tag.RowsAffected() > 0 {
+		return err
+	}
+	_, err = tx.Exec(ctx, r.stmt(pgxStmtVerInsert), args...)
+	return err
+}
+
+// writeStmts returns stmts plus the version history write statements, if on.
+func (r *PgxSecretRepository) writeStmts(stmts ...string) []string {
+	if r.versions != "" {
+		stmts = append(stmts, pgxStmtVerUpdate, pgxStmtVerInsert)
+	}
+	return stmts
+}
+
+// notifyWrite announces a write of key on the notify channel, if any.
+func (r *PgxSecretRepository) notifyWrite(ctx context.Context, key string) {
+	if r.notify == "" {
+		return
+	}
+	_, _ = r.pool.Exec(ctx, "SELECT pg_notify($1, $2)", r.notify, key)
+}
+
+func (r *PgxSecretRepository) GetSecret(ctx context.Context, key string) (*SecretRecord, error) {
+	const op = "repo.GetSecret"
+	if rec, ok := r.cache.Get(key); ok && rec != nil {
+		return rec.clone(), nil
+	}
+	if r.negative.has(key) {
+		return nil, &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	var rec *SecretRecord
+	err := r.withConn(ctx, func(c *pgx.Conn) error {
+		var err error
+		rec, err = scanRecord(c.QueryRow(ctx, r.stmt(pgxStmtGet), key))
+		return err
+	}, pgxStmtGet)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.negative.add(key)
+		return nil, &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	if err != nil {
+		return nil, &Error{Op: op, Key: key, Err: err}
+	}
+	r.cache.Set(key, rec.clone())
+	return rec, nil
+}
+
+func (r *PgxSecretRepository) CreateSecret(ctx context.Context, rec *SecretRecord) error {
+	const op = "repo.CreateSecret"
+	args, err := recordArgs(rec)
+	if err != nil {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+	}
+	err = r.inTx(ctx, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, r.stmt(pgxStmtExists), rec.Key).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyExists
+		}
+		if _, err := tx.Exec(ctx, r.stmt(pgxStmtCreate), args...); err != nil {
+			return err
+		}
+		return r.writeVersion(ctx, tx, args)
+	}, r.writeStmts(pgxStmtExists, pgxStmtCreate)...)
+	if isUniqueViolation(err) {
+		err = ErrAlreadyExists
+	}
+	if err != nil {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+	}
+	r.cache.Set(rec.Key, rec.clone())
+	r.negative.forget(rec.Key)
+	r.notifyWrite(ctx, rec.Key)
+	return nil
+}
+
+func (r *PgxSecretRepository) UpdateSecret(ctx context.Context, rec *SecretRecord, pre Precondition) error {
+	const op = "repo.UpdateSecret"
+	args, err := recordArgs(rec)
+	if err != nil {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+	}
+	var modifiedAt *time.Time
+	if !pre.ModifiedAt.IsZero() {
+		modifiedAt = &pre.ModifiedAt
+	}
+	var n int64
+	err = r.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, r.stmt(pgxStmtUpdate), append(args, pre.Version, modifiedAt)...)
+		if n = tag.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return r.writeVersion(ctx, tx, args)
+	}, r.writeStmts(pgxStmtUpdate)...)
+	if err != nil {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+	}
+	r.cache.Delete(rec.Key)
+	if n == 0 {
+		exists, err := r.exists(ctx, rec.Key)
+		if err != nil {
+			return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+		}
+		if !exists {
+			return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: ErrNotFound}
+		}
+		return pre.conflict(op, rec.Key)
+	}
+	r.notifyWrite(ctx, rec.Key)
+	return nil
+}
+
+func (r *PgxSecretRepository) exists(ctx context.Context, key string) (bool, error) {
+	var ok bool
+	err := r.withConn(ctx, func(c *pgx.Conn) error {
+		return c.QueryRow(ctx, r.stmt(pgxStmtExists), key).Scan(&ok)
+	}, pgxStmtExists)
+	return ok, err
+}
+
+func (r *PgxSecretRepository) SoftDeleteSecret(ctx context.Context, key string) error {
+	return r.delete(ctx, "repo.SoftDeleteSecret", key, pgxStmtSoftDel, pgxStmtVerSoftDel, key, string(StatusDeleted), time.Now().UTC())
+}
+
+// HardDeleteSecret removes the record and its version history.
+func (r *PgxSecretRepository) HardDeleteSecret(ctx context.Context, key string) error {
+	return r.delete(ctx, "repo.HardDeleteSecret", key, pgxStmtHardDel, pgxStmtVerHardDel, key)
+}
+
+func (r *PgxSecretRepository) delete(ctx context.Context, op, key, stmt, histStmt string, args ...any) error {
+	n, err := r.exec(ctx, stmt, histStmt, args...)
+	if err != nil {
+		return &Error{Op: op, Key: key, Err: err}
+	}
+	r.cache.Delete(key)
+	if n == 0 {
+		return &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	r.notifyWrite(ctx, key)
+	return nil
+}
+
+// UpdateWrappedDEK is PostgresSecretRepository.UpdateWrappedDEK.
+func (r *PgxSecretRepository) UpdateWrappedDEK(ctx context.Context, key, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error {
+	n, err := r.exec(ctx, pgxStmtRewrap, pgxStmtVerRewrap, key, oldWrappedDEK, newWrappedDEK, newKEKKeyID, time.Now().UTC())
+	if err != nil {
+		return &Error{Op: "repo.UpdateWrappedDEK", Key: key, Err: err}
+	}
+	r.cache.Delete(key)
+	if n == 0 {
+		return errorf("repo.UpdateWrappedDEK", key, ErrConflict, "record missing or wrapped dek changed")
+	}
+	r.notifyWrite(ctx, key)
+	return nil
+}
+
+// UpdateVersionWrappedDEK is PostgresSecretRepository.UpdateVersionWrappedDEK.
+func (r *PgxSecretRepository) UpdateVersionWrappedDEK(ctx context.Context, key, version, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error {
+	const op = "repo.UpdateVersionWrappedDEK"
+	if r.versions == "" {
+		return errorf(op, key, ErrNotSupported, "version history is disabled")
+	}
+	var n int64
+	err := r.withConn(ctx, func(c *pgx.Conn) error {
+		tag, err := c.Exec(ctx, r.stmt(pgxStmtVerRewrap1), key, version, oldWrappedDEK, newWrappedDEK, newKEKKeyID, time.Now().UTC())
+		n = tag.RowsAffected()
+		return err
+	}, pgxStmtVerRewrap1)
+	if err != nil {
+		return &Error{Op: op, Key: key, Err: err}
+	}
+	if n == 0 {
+		return errorf(op, key, ErrConflict, "version %q missing or wrapped dek changed", version)
+	}
+	return nil
+}
+
+// GetSecretVersion is PostgresSecretRepository.GetSecretVersion.
+func (r *PgxSecretRepository) GetSecretVersion(ctx context.Context, key, version string) (*SecretRecord, error) {
+	const op = "repo.GetSecretVersion"
+	if version == "" || version == VersionCurrent {
+		return r.GetSecret(ctx, key)
+	}
+	if r.versions == "" {
+		return nil, errorf(op, key, ErrNotSupported, "version history is disabled")
+	}
+	stmt, arg := pgxStmtVerGet, version
+	if version == VersionPrevious {
+		cur, err := r.GetSecret(ctx, key)
+		if err != nil {
+			return nil, withKey(err, op, key, "")
+		}
+		stmt, arg = pgxStmtVerPrev, cur.Version
+	}
+	var rec *SecretRecord
+	err := r.withConn(ctx, func(c *pgx.Conn) error {
+		var err error
+		rec, err = scanRecord(c.QueryRow(ctx, r.stmt(stmt), key, arg))
+		return err
+	}, stmt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errorf(op, key, ErrNotFound, "no version %q", version)
+	}
+	if err != nil {
+		return nil, &Error{Op: op, Key: key, Err: err}
+	}
+	return rec, nil
+}
+
+// ListVersions is PostgresSecretRepository.ListVersions.
+func (r *PgxSecretRepository) ListVersions(ctx context.Context, key string) ([]*SecretRecord, error) {
+	const op = "repo.ListVersions"
+	if r.versions == "" {
+		return nil, errorf(op, key, ErrNotSupported, "version history is disabled")
+	}
+	var recs []*SecretRecord
+	err := r.withConn(ctx, func(c *pgx.Conn) error {
+		rows, err := c.Query(ctx, r.stmt(pgxStmtVerList), key)
+		if err != nil {
+			return err
+		}
+		recs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*SecretRecord, error) {
+			return scanRecord(row)
+		})
+		return err
+	}, pgxStmtVerList)
+	if err != nil {
+		return nil, &Error{Op: op, Key: key, Err: err}
+	}
+	if len(recs) == 0 {
+		return nil, &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	return recs, nil
+}
+
+func (r *PgxSecretRepository) ListSecrets(ctx context.Context, opts ListOptions) (*SecretPage, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if opts.TenantID != uuid.Nil {
+		add(r.col("tenant_id")+" = $%d", opts.TenantID)
+	}
+	if opts.Store != "" {
+		add(r.col("store")+" = $%d", string(opts.Store))
+	}
+	if opts.Status != "" {
+		add(r.col("status")+" = $%d", string(opts.Status))
+	}
+	if opts.Name != "" {
+		add(r.col("name")+" = $%d", opts.Name)
+	}
+	if len(opts.Tags) > 0 {
+		doc, err := json.Marshal(opts.Tags)
+		if err != nil {
+			return nil, &Error{Op: "repo.ListSecrets", Err: err}
+		}
+		add(r.col("tags")+" @> $%d::jsonb", string(doc))
+	}
+	if opts.KeyPrefix != "" {
+		add(r.col("key")+` LIKE $%d ESCAPE '\'`, likeEscaper.Replace(opts.KeyPrefix)+"%")
+	}
+	if opts.Cursor != "" {
+		add(r.col("key")+" > $%d", opts.Cursor)
+	}
+	limit := opts.limit()
+	sql := "SELECT " + r.selects + " FROM " + r.table
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += fmt.Sprintf(" ORDER BY %s LIMIT %d", r.col("key"), limit+1)
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, &Error{Op: "repo.ListSecrets", Err: err}
+	}
+	defer rows.Close()
+	var recs []*SecretRecord
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, &Error{Op: "repo.ListSecrets", Err: err}
+		}
+		recs = append(recs, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &Error{Op: "repo.ListSecrets", Err: err}
+	}
+	return newSecretPage(recs, limit), nil
+}
+
+// scanRecord reads one row selected with pgxColumns.
+func scanRecord(row pgx.Row) (*SecretRecord, error) {
+	var (
+		rec             SecretRecord
+		status, store   string
+		meta, tags, acl []byte
+	)
+	err := row.Scan(&rec.ID, &rec.TenantID, &rec.OwnerID, &rec.Issuer, &rec.Name, &rec.Version, &rec.Description, &status,
+		&meta, &tags, &rec.CreatedAt, &rec.CreatedBy, &rec.ModifiedAt, &rec.ModifiedBy,
+		&rec.Key, &store, &rec.Value, &acl, &rec.IV, &rec.Tag, &rec.WrappedDEK, &rec.KEKKeyID, &rec.DEKAlg, &rec.KEKAlg,
+		&rec.AADScheme, &rec.Commitment, &rec.StoreVersion)
+	if err != nil {
+		return nil, err
+	}
+	rec.Status, rec.Store = Status(status), Store(store)
+	for _, j := range []struct {
+		src []byte
+		dst any
+	}{{meta, &rec.Metadata.Data}, {tags, &rec.Tags.Data}, {acl, &rec.ACL.Data}} {
+		if len(j.src) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(j.src, j.dst); err != nil {
+			return nil, fmt.Errorf("decode jsonb column: %w", err)
+		}
+	}
+	return &rec, nil
+}
+
+// recordArgs returns rec's values in pgxColumns order, JSONB columns encoded
+// as GORM would.
+func recordArgs(rec *SecretRecord) ([]any, error) {
+	meta, err := json.Marshal(rec.Metadata.Data)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := json.Marshal(rec.Tags.Data)
+	if err != nil {
+		return nil, err
+	}
+	acl, err := json.Marshal(rec.ACL.Data)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rec.ID, rec.TenantID, rec.OwnerID, rec.Issuer, rec.Name, rec.Version, rec.Description, string(rec.Status),
+		string(meta), string(tags), rec.CreatedAt, rec.CreatedBy, rec.ModifiedAt, rec.ModifiedBy,
+		rec.Key, string(rec.Store), rec.Value, string(acl), rec.IV, rec.Tag, rec.WrappedDEK, rec.KEKKeyID, rec.DEKAlg, rec.KEKAlg,
+		rec.AADScheme, rec.Commitment, rec.StoreVersion,
+	}, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,133 @@
+package vault_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// pgTable creates a fresh secrets table in the Postgres at DSVAULT_PG_DSN and
+// drops it when tb ends. It skips tb if the variable is not set.
+func pgTable(tb testing.TB) (dsn, table string, db *gorm.DB) {
+	tb.Helper()
+	dsn = os.Getenv("DSVAULT_PG_DSN")
+	if dsn == "" {
+		tb.Skip("DSVAULT_PG_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: logger.Discard})
+	require.NoError(tb, err)
+	sqlDB, err := db.DB()
+	require.NoError(tb, err)
+	table = "secrets_test_" + uuid.NewString()[:8]
+	require.NoError(tb, vault.Migrate(context.Background(), sqlDB, table))
+	tb.Cleanup(func() { _ = vault.MigrateDown(context.Background(), sqlDB, table, 0) })
+	return dsn, table, db
+}
+
+func newPgxRepo(tb testing.TB, dsn, table string, opts ...vault.RepositoryOption) *vault.PgxSecretRepository {
+	tb.Helper()
+	pool, err := pgxpool.New(context.Background(), dsn)
+	require.NoError(tb, err)
+	tb.Cleanup(pool.Close)
+	repo, err := vault.NewPgxSecretRepository(pool, table, opts...)
+	require.NoError(tb, err)
+	return repo
+}
+
+func TestPgxSecretRepository_Options(t *testing.T) {
+	t.Parallel()
+	_, err := vault.NewPgxSecretRepository(nil, "secrets; DROP TABLE x")
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	_, err = vault.NewPgxSecretRepository(nil, "secrets", vault.WithColumnMapping(map[string]string{"no_such_column": "x"}))
+	require.ErrorIs(t, err, vault.ErrInvalidArgument)
+	repo, err := vault.NewPgxSecretRepository(nil, "public.secrets", vault.WithoutCache())
+	require.NoError(t, err)
+	require.Error(t, repo.SetNotifyChannel("bad-channel"))
+}
+
+func TestPgxSecretRepository_Postgres(t *testing.T) {
+	ctx := context.Background()
+	dsn, table, _ := pgTable(t)
+	repo := newPgxRepo(t, dsn, table)
+	repo.SetNegativeCacheTTL(time.Minute)
+
+	_, err := repo.GetSecret(ctx, "svc/missing")
+	require.ErrorIs(t, err, vault.ErrNotFound)
+	_, err = repo.GetSecret(ctx, "svc/missing")
+	require.ErrorIs(t, err, vault.ErrNotFound)
+	require.Equal(t, vault.NegativeCacheStats{Hits: 1, Stores: 1, Entries: 1}, repo.NegativeCacheStats())
+
+	// Rows written through GORM read back identically through pgx.
+	gormRepo, err := vault.NewGormSecretRepository(postgres.Open(dsn), table, vault.WithoutCache())
+	require.NoError(t, err)
+	rec := conformanceRecord(uuid.New(), "svc/gorm")
+	rec.ACL.Data = map[string][]string{"read": {"svc-a"}}
+	require.NoError(t, gormRepo.CreateSecret(ctx, rec))
+	got, err := repo.GetSecret(ctx, "svc/gorm")
+	require.NoError(t, err)
+	want, err := gormRepo.GetSecret(ctx, "svc/gorm")
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	require.NoError(t, repo.UpdateWrappedDEK(ctx, "svc/gorm", "", "new", "alias/next"))
+	require.ErrorIs(t, repo.UpdateWrappedDEK(ctx, "svc/gorm", "", "newer", "alias/next"), vault.ErrConflict)
+}
+
+const benchKey = "svc/bench/000"
+
+func benchRepos(b *testing.B) map[string]vault.SecretRepository {
+	dsn, table, _ := pgTable(b)
+	gormRepo, err := vault.NewGormSecretRepository(postgres.Open(dsn), table,
+		vault.WithoutCache(), vault.WithLogger(logger.Discard))
+	require.NoError(b, err)
+	tenantID := uuid.New()
+	for i := range 200 {
+		require.NoError(b, gormRepo.CreateSecret(context.Background(), conformanceRecord(tenantID, fmt.Sprintf("svc/bench/%03d", i))))
+	}
+	return map[string]vault.SecretRepository{
+		"gorm": gormRepo,
+		"pgx":  newPgxRepo(b, dsn, table, vault.WithoutCache()),
+	}
+}
+
+// BenchmarkRepository_GetSecret compares uncached point reads.
+func BenchmarkRepository_GetSecret(b *testing.B) {
+	ctx := context.Background()
+	for name, repo := range benchRepos(b) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := repo.GetSecret(ctx, benchKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRepository_ListSecrets compares a filtered page of 50 records.
+func BenchmarkRepository_ListSecrets(b *testing.B) {
+	ctx := context.Background()
+	opts := vault.ListOptions{KeyPrefix: "svc/bench/", Status: vault.StatusActive, Limit: 50}
+	for name, repo := range benchRepos(b) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := repo.ListSecrets(ctx, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
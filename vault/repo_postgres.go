@@ -3,63 +3,546 @@ package vault
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var validTable = regexp.MustCompile(`^[A-Za-z0-9_\\.]+$`)
+var (
+	validTable = regexp.MustCompile(`^[A-Za-z0-9_\\.]+$`)
+	validIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
 
-type SecretRepository interface {
+// SecretReader is what a Client needs from its repository: loading a record
+// by key. It returns an error wrapping ErrNotFound (or a nil record) when the
+// key does not exist.
+type SecretReader interface {
 	GetSecret(ctx context.Context, key string) (*SecretRecord, error)
 }
 
+// SecretRepository is the full read/write interface over stored records.
+// PostgresSecretRepository and InMemoryRepo implement it and behave the same.
+type SecretRepository interface {
+	SecretReader
+	SecretWriter
+	SecretLister
+	// SoftDeleteSecret marks the record for key as StatusDeleted and bumps
+	// ModifiedAt; the row is kept. It fails with ErrNotFound if key is absent.
+	SoftDeleteSecret(ctx context.Context, key string) error
+	// HardDeleteSecret removes the record for key. It fails with ErrNotFound
+	// if key is absent.
+	HardDeleteSecret(ctx context.Context, key string) error
+}
+
+// SecretWriter is implemented by repositories that can persist records.
+// Client.PutSecret and Client.RotateSecret require their repository to
+// implement it.
+type SecretWriter interface {
+	// CreateSecret inserts rec; it fails if a record with rec.Key exists.
+	CreateSecret(ctx context.Context, rec *SecretRecord) error
+	// UpdateSecret replaces the stored record for rec.Key, provided the
+	// stored row still satisfies pre.
+	UpdateSecret(ctx context.Context, rec *SecretRecord, pre Precondition) error
+}
+
+// SecretLister is implemented by repositories that can enumerate records.
+type SecretLister interface {
+	// ListSecrets returns one page of records ordered by Key.
+	ListSecrets(ctx context.Context, opts ListOptions) (*SecretPage, error)
+}
+
+// ListOptions filters and pages ListSecrets. Zero-valued filters match all;
+// set filters must all match.
+type ListOptions struct {
+	TenantID  uuid.UUID
+	Store     Store
+	Status    Status
+	Name      string
+	Tags      map[string]string // every pair must be present in the record's Tags
+	KeyPrefix string
+	Cursor    string // resume after this cursor (SecretPage.NextCursor)
+	Limit     int    // page size; defaults to 100
+}
+
+// SecretPage is one page of ListSecrets results. NextCursor is empty on the
+// last page.
+type SecretPage struct {
+	Records    []*SecretRecord
+	NextCursor string
+}
+
+const defaultListLimit = 100
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return defaultListLimit
+	}
+	return o.Limit
+}
+
+// matches reports whether rec passes the filters in o, cursor included.
+func (o ListOptions) matches(rec *SecretRecord) bool {
+	switch {
+	case o.TenantID != uuid.Nil && rec.TenantID != o.TenantID,
+		o.Store != "" && rec.Store != o.Store,
+		o.Status != "" && rec.Status != o.Status,
+		o.Name != "" && rec.Name != o.Name,
+		!strings.HasPrefix(rec.Key, o.KeyPrefix),
+		o.Cursor != "" && rec.Key <= o.Cursor:
+		return false
+	}
+	for k, v := range o.Tags {
+		if got, ok := rec.Tags.Data[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Precondition guards UpdateSecret with optimistic concurrency. Zero-valued
+// fields are not checked.
+type Precondition struct {
+	Version    string    // stored Version must equal this
+	ModifiedAt time.Time // stored ModifiedAt must equal this
+}
+
+// check reports whether a stored record satisfies p.
+func (p Precondition) check(cur *SecretRecord) bool {
+	if p.Version != "" && cur.Version != p.Version {
+		return false
+	}
+	return p.ModifiedAt.IsZero() || cur.ModifiedAt.Equal(p.ModifiedAt)
+}
+
+// conflict is the error UpdateSecret returns when p no longer holds.
+func (p Precondition) conflict(op, key string) error {
+	if p.ModifiedAt.IsZero() {
+		return errorf(op, key, ErrConflict, "version is no longer %q", p.Version)
+	}
+	return errorf(op, key, ErrConflict, "record changed since %s", p.ModifiedAt.Format(time.RFC3339Nano))
+}
+
 type PostgresSecretRepository struct {
-	db    *gorm.DB
-	table string
-	cache *TTLCache[*SecretRecord]
+	db     *gorm.DB
+	table  string
+	cache  *TTLCache[*SecretRecord]
+	notify string // NOTIFY channel for writes; empty disables
+
+	negative *negativeCache // nil unless SetNegativeCacheTTL
+
+	columns  map[string]string // default column name -> table column (WithColumnMapping)
+	selects  []string          // "<column> AS <default>" when columns are mapped
+	versions string            // version history table; empty without WithVersionHistory
 }
 
 func (p *PostgresSecretRepository) SetDB(db *gorm.DB) { p.db = db }
 
-func NewPostgresSecretRepository(dsn, table string) (*PostgresSecretRepository, error) {
-	return NewGormSecretRepository(postgres.Open(dsn), table)
+// SetNotifyChannel makes every successful write send the record key to the
+// Postgres NOTIFY channel, for InvalidationListeners in other processes. The
+// notification is best effort: if it fails, the write still succeeds and
+// listeners fall back to their cache TTLs. An empty channel disables it.
+func (p *PostgresSecretRepository) SetNotifyChannel(channel string) error {
+	if channel != "" && !validIdent.MatchString(channel) {
+		return errorf("repo.SetNotifyChannel", "", ErrInvalidArgument, "invalid channel name: %s", channel)
+	}
+	p.notify = channel
+	return nil
 }
 
-func NewGormSecretRepository(dialector gorm.Dialector, table string) (*PostgresSecretRepository, error) {
-	if !validTable.MatchString(table) {
-		return nil, fmt.Errorf("invalid table name: %s", table)
-	}
-	db, err := gorm.Open(dialector, &gorm.Config{})
-	if err != nil {
-		return nil, err
+// SetNegativeCacheTTL makes GetSecret remember keys that were not found for
+// ttl, answering repeated reads of them with ErrNotFound without a query.
+// Writes through this repository and Invalidate forget the key again.
+// ttl <= 0 disables negative caching, which is the default.
+func (p *PostgresSecretRepository) SetNegativeCacheTTL(ttl time.Duration) {
+	p.negative = newNegativeCache(ttl)
+}
+
+// NegativeCacheStats reports on the negative cache (see SetNegativeCacheTTL).
+func (p *PostgresSecretRepository) NegativeCacheStats() NegativeCacheStats {
+	return p.negative.stats()
+}
+
+// PurgeNegativeCache forgets every key remembered as not found.
+func (p *PostgresSecretRepository) PurgeNegativeCache() {
+	p.negative.purge()
+}
+
+// notifyWrite announces a write of key on the notify channel, if any.
+func (r *PostgresSecretRepository) notifyWrite(ctx context.Context, key string) {
+	if r.notify == "" {
+		return
 	}
-	return &PostgresSecretRepository{
-		db: db, table: table,
-		cache: NewTTLCache[*SecretRecord](4096, time.Minute),
-	}, nil
+	_ = r.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", r.notify, key).Error
+}
+
+// NewPostgresSecretRepository opens the Postgres database at dsn and reads
+// records from table. See RepositoryOption for the defaults it changes.
+func NewPostgresSecretRepository(dsn, table string, opts ...RepositoryOption) (*PostgresSecretRepository, error) {
+	return NewGormSecretRepository(postgres.Open(dsn), table, opts...)
+}
+
+// NewGormSecretRepository is NewPostgresSecretRepository for any gorm
+// dialector. With WithExistingDB, dialector is ignored and may be nil.
+func NewGormSecretRepository(dialector gorm.Dialector, table string, opts ...RepositoryOption) (*PostgresSecretRepository, error) {
+	return newRepository(dialector, table, opts)
 }
 
 func (r *PostgresSecretRepository) GetSecret(ctx context.Context, key string) (*SecretRecord, error) {
 	if rec, ok := r.cache.Get(key); ok && rec != nil {
-		return rec, nil
+		return rec.clone(), nil
+	}
+	if r.negative.has(key) {
+		return nil, &Error{Op: "repo.GetSecret", Key: key, Err: ErrNotFound}
 	}
 	var sec SecretRecord
 
-	tx := r.db.WithContext(ctx).
-		Table(r.table).
-		Where("key = ?", key)
+	tx := r.query(ctx).
+		Where(r.col("key")+" = ?", key)
 
-	if err := tx.First(&sec).Error; err != nil {
+	if err := tx.Take(&sec).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("not found, %v", err)
+			r.negative.add(key)
+			return nil, &Error{Op: "repo.GetSecret", Key: key, Err: ErrNotFound}
 		}
-		return nil, err
+		return nil, &Error{Op: "repo.GetSecret", Key: key, Err: err}
 	}
-	r.cache.Set(key, &sec)
+	r.cache.Set(key, sec.clone())
 	return &sec, nil
 }
+
+func (r *PostgresSecretRepository) CreateSecret(ctx context.Context, rec *SecretRecord) error {
+	const op = "repo.CreateSecret"
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Table(r.table).Where(r.col("key")+" = ?", rec.Key).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrAlreadyExists
+		}
+		if err := tx.Table(r.table).Create(r.values(rec)).Error; err != nil {
+			return err
+		}
+		return r.writeVersion(tx, rec)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		err = ErrAlreadyExists
+	}
+	if err != nil {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+	}
+	r.cache.Set(rec.Key, rec.clone())
+	r.negative.forget(rec.Key)
+	r.notifyWrite(ctx, rec.Key)
+	return nil
+}
+
+func (r *PostgresSecretRepository) UpdateSecret(ctx context.Context, rec *SecretRecord, pre Precondition) error {
+	const op = "repo.UpdateSecret"
+	var affected int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Table(r.table).Where(r.col("key")+" = ?", rec.Key)
+		if pre.Version != "" {
+			q = q.Where(r.col("version")+" = ?", pre.Version)
+		}
+		if !pre.ModifiedAt.IsZero() {
+			q = q.Where(r.col("modified_at")+" = ?", pre.ModifiedAt)
+		}
+		if len(r.columns) == 0 {
+			q = q.Select("*") // also write zero values; column maps always do
+		}
+		res := q.Updates(r.values(rec))
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		affected = res.RowsAffected
+		return r.writeVersion(tx, rec)
+	})
+	if err != nil {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+	}
+	r.cache.Delete(rec.Key)
+	if affected == 0 {
+		var n int64
+		if err := r.db.WithContext(ctx).Table(r.table).Where(r.col("key")+" = ?", rec.Key).Count(&n).Error; err != nil {
+			return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: err}
+		}
+		if n == 0 {
+			return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: ErrNotFound}
+		}
+		return pre.conflict(op, rec.Key)
+	}
+	r.notifyWrite(ctx, rec.Key)
+	return nil
+}
+
+func (r *PostgresSecretRepository) SoftDeleteSecret(ctx context.Context, key string) error {
+	const op = "repo.SoftDeleteSecret"
+	set := map[string]any{
+		r.col("status"):      StatusDeleted,
+		r.col("modified_at"): time.Now().UTC(),
+	}
+	var affected int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table(r.table).Where(r.col("key")+" = ?", key).Updates(set)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		affected = res.RowsAffected
+		if r.versions == "" {
+			return nil
+		}
+		current := tx.Table(r.table).Select(r.col("version")).Where(r.col("key")+" = ?", key)
+		return tx.Table(r.versions).
+			Where(r.col("key")+" = ? AND "+r.col("version")+" = (?)", key, current).
+			Updates(set).Error
+	})
+	if err != nil {
+		return &Error{Op: op, Key: key, Err: err}
+	}
+	r.cache.Delete(key)
+	if affected == 0 {
+		return &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	r.notifyWrite(ctx, key)
+	return nil
+}
+
+// HardDeleteSecret removes the record and its version history.
+func (r *PostgresSecretRepository) HardDeleteSecret(ctx context.Context, key string) error {
+	const op = "repo.HardDeleteSecret"
+	var affected int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table(r.table).Where(r.col("key")+" = ?", key).Delete(&SecretRecord{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		affected = res.RowsAffected
+		if r.versions == "" {
+			return nil
+		}
+		return tx.Table(r.versions).Where(r.col("key")+" = ?", key).Delete(&SecretRecord{}).Error
+	})
+	if err != nil {
+		return &Error{Op: op, Key: key, Err: err}
+	}
+	r.cache.Delete(key)
+	if affected == 0 {
+		return &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	r.notifyWrite(ctx, key)
+	return nil
+}
+
+// writeVersion records rec in the version history: it replaces the row for
+// rec.Version, or appends one after the key's latest.
+func (r *PostgresSecretRepository) writeVersion(tx *gorm.DB, rec *SecretRecord) error {
+	if r.versions == "" {
+		return nil
+	}
+	q := tx.Table(r.versions).Where(r.col("key")+" = ? AND "+r.col("version")+" = ?", rec.Key, rec.Version)
+	if len(r.columns) == 0 {
+		q = q.Select("*")
+	}
+	res := q.Updates(r.values(rec))
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	var seq int64
+	err := tx.Table(r.versions).Select("COALESCE(MAX(seq), 0) + 1").
+		Where(r.col("key")+" = ?", rec.Key).Scan(&seq).Error
+	if err != nil {
+		return err
+	}
+	row := r.columnValues(rec)
+	row["seq"] = seq
+	return tx.Table(r.versions).Create(row).Error
+}
+
+// GetSecretVersion returns version of key from the version history. The
+// VersionCurrent alias (or "") is GetSecret; VersionPrevious is the version
+// written before the current one.
+func (r *PostgresSecretRepository) GetSecretVersion(ctx context.Context, key, version string) (*SecretRecord, error) {
+	const op = "repo.GetSecretVersion"
+	if version == "" || version == VersionCurrent {
+		return r.GetSecret(ctx, key)
+	}
+	if r.versions == "" {
+		return nil, errorf(op, key, ErrNotSupported, "version history is disabled")
+	}
+	tx := r.query(ctx).Table(r.versions).Where(r.col("key")+" = ?", key)
+	if version == VersionPrevious {
+		cur, err := r.GetSecret(ctx, key)
+		if err != nil {
+			return nil, withKey(err, op, key, "")
+		}
+		seq := r.db.WithContext(ctx).Table(r.versions).Select("seq").
+			Where(r.col("key")+" = ? AND "+r.col("version")+" = ?", key, cur.Version)
+		tx = tx.Where("seq < (?)", seq).Order("seq DESC")
+	} else {
+		tx = tx.Where(r.col("version")+" = ?", version)
+	}
+	var rec SecretRecord
+	if err := tx.Take(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorf(op, key, ErrNotFound, "no version %q", version)
+		}
+		return nil, &Error{Op: op, Key: key, Err: err}
+	}
+	return &rec, nil
+}
+
+// ListVersions returns every recorded version of key, oldest first.
+func (r *PostgresSecretRepository) ListVersions(ctx context.Context, key string) ([]*SecretRecord, error) {
+	const op = "repo.ListVersions"
+	if r.versions == "" {
+		return nil, errorf(op, key, ErrNotSupported, "version history is disabled")
+	}
+	var recs []*SecretRecord
+	if err := r.query(ctx).Table(r.versions).Where(r.col("key")+" = ?", key).Order("seq").Find(&recs).Error; err != nil {
+		return nil, &Error{Op: op, Key: key, Err: err}
+	}
+	if len(recs) == 0 {
+		return nil, &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+	return recs, nil
+}
+
+// Invalidate drops key from the record and negative caches so the next GetSecret reads
+// the row again.
+func (r *PostgresSecretRepository) Invalidate(key string) {
+	r.cache.Delete(key)
+	r.negative.forget(key)
+}
+
+// Purge drops every cached record and negative cache entry.
+func (r *PostgresSecretRepository) Purge() {
+	r.cache.Clear()
+	r.negative.purge()
+}
+
+func (r *PostgresSecretRepository) ListSecrets(ctx context.Context, opts ListOptions) (*SecretPage, error) {
+	tx := r.query(ctx)
+	if opts.TenantID != uuid.Nil {
+		tx = tx.Where(r.col("tenant_id")+" = ?", opts.TenantID)
+	}
+	if opts.Store != "" {
+		tx = tx.Where(r.col("store")+" = ?", opts.Store)
+	}
+	if opts.Status != "" {
+		tx = tx.Where(r.col("status")+" = ?", opts.Status)
+	}
+	if opts.Name != "" {
+		tx = tx.Where(r.col("name")+" = ?", opts.Name)
+	}
+	for k, v := range opts.Tags {
+		tx = r.whereTag(tx, k, v)
+	}
+	if opts.KeyPrefix != "" {
+		tx = tx.Where(r.col("key")+` LIKE ? ESCAPE '\'`, likeEscaper.Replace(opts.KeyPrefix)+"%")
+	}
+	if opts.Cursor != "" {
+		tx = tx.Where(r.col("key")+" > ?", opts.Cursor)
+	}
+	limit := opts.limit()
+	var recs []*SecretRecord
+	if err := tx.Order(r.col("key")).Limit(limit + 1).Find(&recs).Error; err != nil {
+		return nil, &Error{Op: "repo.ListSecrets", Err: err}
+	}
+	return newSecretPage(recs, limit), nil
+}
+
+// TagFilterDialector is implemented by gorm dialectors without Postgres'
+// jsonb containment (the SQLite one in internal/fakes, for tests).
+// ListSecrets calls WhereTag to restrict tx to rows whose tags column holds
+// k = v, instead of filtering with "@>".
+type TagFilterDialector interface {
+	gorm.Dialector
+	WhereTag(tx *gorm.DB, column, k, v string) *gorm.DB
+}
+
+// whereTag restricts tx to rows whose tags hold k = v.
+func (r *PostgresSecretRepository) whereTag(tx *gorm.DB, k, v string) *gorm.DB {
+	if d, ok := r.db.Dialector.(TagFilterDialector); ok {
+		return d.WhereTag(tx, r.col("tags"), k, v)
+	}
+	doc, _ := json.Marshal(map[string]string{k: v})
+	return tx.Where(r.col("tags")+" @> ?::jsonb", string(doc))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// newSecretPage trims recs (fetched with limit+1) to limit and derives the
+// next cursor from the last record kept.
+func newSecretPage(recs []*SecretRecord, limit int) *SecretPage {
+	page := &SecretPage{Records: recs}
+	if len(recs) > limit {
+		page.Records = recs[:limit]
+		page.NextCursor = recs[limit-1].Key
+	}
+	return page
+}
+
+// UpdateWrappedDEK swaps the record's WrappedDEK and KEKKeyID in a single
+// conditional UPDATE, and in the version history row that shares the DEK.
+// It fails if the stored WrappedDEK is no longer oldWrappedDEK. Ciphertext
+// columns are not touched.
+func (r *PostgresSecretRepository) UpdateWrappedDEK(ctx context.Context, key, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error {
+	set := map[string]any{
+		r.col("wrapped_dek"): newWrappedDEK,
+		r.col("kek_key_id"):  newKEKKeyID,
+		r.col("modified_at"): time.Now().UTC(),
+	}
+	var affected int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		where := r.col("key") + " = ? AND " + r.col("wrapped_dek") + " = ?"
+		res := tx.Table(r.table).Where(where, key, oldWrappedDEK).Updates(set)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		affected = res.RowsAffected
+		if r.versions == "" {
+			return nil
+		}
+		return tx.Table(r.versions).Where(where, key, oldWrappedDEK).Updates(set).Error
+	})
+	if err != nil {
+		return &Error{Op: "repo.UpdateWrappedDEK", Key: key, Err: err}
+	}
+	r.cache.Delete(key)
+	if affected == 0 {
+		return errorf("repo.UpdateWrappedDEK", key, ErrConflict, "record missing or wrapped dek changed")
+	}
+	r.notifyWrite(ctx, key)
+	return nil
+}
+
+// UpdateVersionWrappedDEK is UpdateWrappedDEK for the history row of version
+// alone; the current record is not touched. Rewrapper uses it for the
+// versions before the current one.
+func (r *PostgresSecretRepository) UpdateVersionWrappedDEK(ctx context.Context, key, version, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error {
+	const op = "repo.UpdateVersionWrappedDEK"
+	if r.versions == "" {
+		return errorf(op, key, ErrNotSupported, "version history is disabled")
+	}
+	res := r.db.WithContext(ctx).Table(r.versions).
+		Where(r.col("key")+" = ? AND "+r.col("version")+" = ? AND "+r.col("wrapped_dek")+" = ?", key, version, oldWrappedDEK).
+		Updates(map[string]any{
+			r.col("wrapped_dek"): newWrappedDEK,
+			r.col("kek_key_id"):  newKEKKeyID,
+			r.col("modified_at"): time.Now().UTC(),
+		})
+	if res.Error != nil {
+		return &Error{Op: op, Key: key, Err: res.Error}
+	}
+	if res.RowsAffected == 0 {
+		return errorf(op, key, ErrConflict, "version %q missing or wrapped dek changed", version)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package vault
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResealOptions selects the records Client.ResealSecrets migrates.
+type ResealOptions struct {
+	TenantID  uuid.UUID
+	Store     Store
+	KeyPrefix string
+
+	// Checkpoint resumes a previous run (RewrapReport.Checkpoint).
+	Checkpoint string
+	// BatchSize is the page size used when listing; defaults to 100.
+	BatchSize int
+	// Progress, if set, is called after every batch.
+	Progress func(RewrapProgress)
+}
+
+// ResealSecret migrates key to AADSchemeV2: it decrypts the current
+// ciphertext under the record's scheme and re-encrypts the same plaintext
+// under a fresh DEK, bound to the AADSchemeV2 AAD and EncryptionContext.
+// Version stays the same; the update is guarded by the record's Version and
+// ModifiedAt (ErrConflict if it changed meanwhile), and external stores are
+// restored on failure as in RotateSecret. Records already on AADSchemeV2 are
+// returned unchanged. The key must be canonical and match the record (see
+// ParseKey). Status and ACL are not checked: this is a migration tool.
+func (c *Client) ResealSecret(ctx context.Context, key string) (*SecretRecord, error) {
+	const op = "Client.ResealSecret"
+	rec, err := c.reseal(ctx, key)
+	if err == errSkipRewrap {
+		return rec, nil
+	}
+	if err != nil {
+		return nil, withKey(err, op, key, "")
+	}
+	return rec, nil
+}
+
+// ResealSecrets runs ResealSecret over every matching record, page by page.
+// The report counts resealed records as Rewrapped and records already on
+// AADSchemeV2 as Skipped; failures are collected and do not stop the run.
+// If ctx is cancelled, the report so far is returned with ctx.Err(). The
+// repository must implement SecretLister.
+func (c *Client) ResealSecrets(ctx context.Context, opts ResealOptions) (*RewrapReport, error) {
+	const op = "Client.ResealSecrets"
+	l, ok := c.repo.(SecretLister)
+	if !ok {
+		return nil, errorf(op, "", ErrNotSupported, "repository %T cannot list records", c.repo)
+	}
+	list := ListOptions{
+		TenantID:  opts.TenantID,
+		Store:     opts.Store,
+		KeyPrefix: opts.KeyPrefix,
+		Cursor:    opts.Checkpoint,
+		Limit:     opts.BatchSize,
+	}
+	return walkRecords(ctx, l, list, opts.Progress, func(ctx context.Context, rec *SecretRecord) error {
+		if rec.AADScheme == AADSchemeV2 {
+			return errSkipRewrap
+		}
+		_, err := c.ResealSecret(ctx, rec.Key)
+		return err
+	})
+}
+
+// reseal is ResealSecret, returning errSkipRewrap with the record when it is
+// already on AADSchemeV2.
+func (c *Client) reseal(ctx context.Context, key string) (*SecretRecord, error) {
+	w, ok := c.repo.(SecretWriter)
+	if !ok {
+		return nil, errorf("Client.ResealSecret", key, ErrNotSupported, "repository %T does not support writes", c.repo)
+	}
+	if inv, ok := c.repo.(KeyInvalidator); ok {
+		inv.Invalidate(key)
+	}
+	cur, err := c.repo.GetSecret(ctx, key)
+	if err == nil && cur == nil {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cur.AADScheme == AADSchemeV2 {
+		return cur, errSkipRewrap
+	}
+	if err := c.checkKey(key, cur); err != nil {
+		return nil, err
+	}
+
+	cs, err := c.store(cur.Store)
+	if err != nil {
+		return nil, err
+	}
+	if inv, ok := cs.(KeyInvalidator); ok {
+		inv.Invalidate(key)
+	}
+	pt, err := c.open(ctx, cs, cur, false)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(pt)
+
+	next := *cur
+	next.ModifiedAt = time.Now().UTC()
+	if err := c.replace(ctx, w, cur, &next, pt, Precondition{Version: cur.Version, ModifiedAt: cur.ModifiedAt}); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
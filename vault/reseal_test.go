@@ -0,0 +1,144 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// putV1Record stores plaintext under key the way records were sealed before
+// AADSchemeV2, with dek as the data key.
+func putV1Record(t *testing.T, repo *vault.InMemoryRepo, ssm *fakes.SSM, dek []byte, tenantID uuid.UUID, store vault.Store, plaintext string) string {
+	t.Helper()
+	key := vault.MakeKey(uuid.New(), tenantID, string(store), string(vault.EnvDev), "ds", "vault")
+	aad, _ := vault.MakeAADAndEncCtx(tenantID, key)
+	ivB64, valueB64, tagB64, err := fakes.EncryptWithDEK(dek, []byte(plaintext), aad)
+	require.NoError(t, err)
+	rec := &vault.SecretRecord{
+		ID: keySecretID(t, key), TenantID: tenantID, Key: key, Store: store, Status: vault.StatusActive, Version: "v1",
+		IV: ivB64, Tag: tagB64, WrappedDEK: "V1JBUFBFRA==", KEKKeyID: "alias/ds-vault", DEKAlg: vault.DEKAlgAES256GCM,
+		ModifiedAt: time.Now().UTC(),
+	}
+	if store == vault.StoreAWSSSM {
+		ssm.Values[key] = valueB64
+	} else {
+		rec.Value = valueB64
+	}
+	require.NoError(t, repo.CreateSecret(context.Background(), rec))
+	return key
+}
+
+func TestClient_ResealSecret(t *testing.T) {
+	t.Parallel()
+
+	for _, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM} {
+		t.Run(string(store), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			dek := make([]byte, 32)
+			_, _ = rand.Read(dek)
+			tenantID := uuid.New()
+			repo := vault.NewInMemoryRepo()
+			ssmFake := &fakes.SSM{Values: map[string]string{}}
+			client := vault.NewClient(repo,
+				vault.NewKMSProvider(&fakes.KMS{Plaintext: dek}, 1024, 5*time.Minute),
+				vault.NewSSMProvider(ssmFake, 1024, 5*time.Minute),
+				time.Minute, openACL)
+			key := putV1Record(t, repo, ssmFake, dek, tenantID, store, "legacy")
+
+			// Under aad_v1 the version column is not authenticated.
+			v1, err := repo.GetSecret(ctx, key)
+			require.NoError(t, err)
+			edited := *v1
+			edited.Version = "v7"
+			repo.Put(&edited)
+			pt, err := client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, "legacy", string(pt))
+			repo.Put(v1)
+			client.Invalidate(key)
+
+			rec, err := client.ResealSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, vault.AADSchemeV2, rec.AADScheme)
+			require.Equal(t, "v1", rec.Version)
+			require.Equal(t, v1.ID, rec.ID)
+
+			pt, err = client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, "legacy", string(pt))
+
+			again, err := client.ResealSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, rec.WrappedDEK, again.WrappedDEK, "already on aad_v2")
+			require.Equal(t, rec.IV, again.IV)
+
+			// Under aad_v2 it is.
+			edited = *rec
+			edited.Version = "v7"
+			repo.Put(&edited)
+			client.Invalidate(key)
+			_, err = client.GetSecret(ctx, key)
+			require.ErrorIs(t, err, vault.ErrAuthenticationFailed)
+		})
+	}
+}
+
+func TestClient_ResealSecrets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+	tenantID := uuid.New()
+	repo := vault.NewInMemoryRepo()
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	client := vault.NewClient(repo,
+		vault.NewKMSProvider(&fakes.KMS{Plaintext: dek}, 1024, 5*time.Minute),
+		vault.NewSSMProvider(ssmFake, 1024, 5*time.Minute),
+		time.Minute, openACL)
+
+	want := map[string]string{}
+	for i, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM, vault.StoreDSVault} {
+		pt := "legacy-" + string(rune('a'+i))
+		want[putV1Record(t, repo, ssmFake, dek, tenantID, store, pt)] = pt
+	}
+	fresh := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	_, err := client.PutSecret(ctx, fresh, []byte("fresh"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+	want[fresh] = "fresh"
+
+	var batches int
+	rep, err := client.ResealSecrets(ctx, vault.ResealOptions{
+		TenantID:  tenantID,
+		BatchSize: 2,
+		Progress:  func(vault.RewrapProgress) { batches++ },
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, rep.Scanned)
+	require.Equal(t, 3, rep.Rewrapped)
+	require.Equal(t, 1, rep.Skipped)
+	require.Empty(t, rep.Failures)
+	require.Equal(t, 2, batches)
+
+	for key, pt := range want {
+		rec, err := repo.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, vault.AADSchemeV2, rec.AADScheme, key)
+		got, err := client.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, pt, string(got))
+	}
+
+	_, err = vault.NewClient(&stubRepo{}, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute).
+		ResealSecrets(ctx, vault.ResealOptions{})
+	require.ErrorIs(t, err, vault.ErrNotSupported)
+}
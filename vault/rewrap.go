@@ -0,0 +1,325 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RewrapRepository is what a Rewrapper needs from the repository: listing
+// records and swapping their wrapped DEK in place.
+type RewrapRepository interface {
+	SecretLister
+	// UpdateWrappedDEK atomically replaces WrappedDEK and KEKKeyID for key,
+	// provided the stored WrappedDEK still equals oldWrappedDEK.
+	UpdateWrappedDEK(ctx context.Context, key, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error
+}
+
+// VersionRewrapRepository is implemented by RewrapRepositories with a version
+// history. The Rewrapper then also re-wraps the DEKs of older versions, so
+// they stay readable once the source key is disabled.
+type VersionRewrapRepository interface {
+	RewrapRepository
+	SecretVersioner
+	// UpdateVersionWrappedDEK is UpdateWrappedDEK for the history row of
+	// version of key alone.
+	UpdateVersionWrappedDEK(ctx context.Context, key, version, oldWrappedDEK, newWrappedDEK, newKEKKeyID string) error
+}
+
+// Rewrapper migrates wrapped DEKs to a new KMS key (KEK) with KMS ReEncrypt
+// (or any other KeyRewrapper, such as a LocalKeyring).
+// Only SecretRecord.WrappedDEK and KEKKeyID change; ciphertext in the DB or
+// SSM, IV and Tag stay untouched, so existing readers keep working once the
+// new key is usable by them. If the repository implements
+// VersionRewrapRepository, every version in the history is re-wrapped too.
+//
+// Envelope records carry their wrapped DEK in the store. The Rewrapper
+// re-wraps it inside the envelope and writes the envelope back, which needs
+// a CiphertextWriter for the record's store (see WithRewrapStore) and a
+// repository implementing SecretWriter.
+type Rewrapper struct {
+	repo   RewrapRepository
+	keys   KeyRewrapper
+	stores map[Store]CiphertextWriter
+}
+
+// RewrapperOption configures a Rewrapper.
+type RewrapperOption func(*Rewrapper)
+
+// WithRewrapStore registers w for envelope records whose Store is store.
+// StoreDSVault is built in.
+func WithRewrapStore(store Store, w CiphertextWriter) RewrapperOption {
+	return func(rw *Rewrapper) { rw.stores[store] = w }
+}
+
+// NewRewrapper builds a Rewrapper over repo using keys (typically a
+// *KMSProvider) to re-wrap DEKs.
+func NewRewrapper(repo RewrapRepository, keys KeyRewrapper, opts ...RewrapperOption) *Rewrapper {
+	rw := &Rewrapper{repo: repo, keys: keys, stores: map[Store]CiphertextWriter{StoreDSVault: dbStore{}}}
+	for _, opt := range opts {
+		opt(rw)
+	}
+	return rw
+}
+
+// RewrapOptions selects which records to re-wrap and where to.
+type RewrapOptions struct {
+	// DestinationKeyID is the new KEK (KMS key id/arn). Required.
+	DestinationKeyID string
+	// SourceKeyID, when set, limits the run to records whose KEKKeyID
+	// equals it. Records already on DestinationKeyID are always skipped.
+	SourceKeyID string
+
+	TenantID  uuid.UUID
+	Store     Store
+	KeyPrefix string
+
+	// Checkpoint resumes a previous run (RewrapReport.Checkpoint).
+	Checkpoint string
+	// BatchSize is the page size used when listing; defaults to 100.
+	BatchSize int
+	// Progress, if set, is called after every batch.
+	Progress func(RewrapProgress)
+}
+
+// RewrapProgress counts what a run has done so far. Checkpoint is the last
+// key that was fully handled; pass it as RewrapOptions.Checkpoint to resume.
+type RewrapProgress struct {
+	Scanned    int
+	Rewrapped  int
+	Skipped    int
+	Failed     int
+	Checkpoint string
+}
+
+// RewrapFailure records a key that could not be re-wrapped.
+type RewrapFailure struct {
+	Key string
+	Err error
+}
+
+// RewrapReport is the outcome of Rewrapper.Run. Failed keys are not retried
+// when resuming from Checkpoint; re-run them individually (KeyPrefix=key).
+type RewrapReport struct {
+	RewrapProgress
+	Failures []RewrapFailure
+}
+
+// Run walks the repository page by page and re-wraps every matching record.
+// Per-record failures are collected in the report and do not stop the run.
+// If ctx is cancelled, Run returns the report so far (with a Checkpoint to
+// resume from) together with ctx.Err(). Listing errors abort the run.
+func (w *Rewrapper) Run(ctx context.Context, opts RewrapOptions) (*RewrapReport, error) {
+	if opts.DestinationKeyID == "" {
+		return nil, errorf("Rewrapper.Run", "", ErrInvalidArgument, "destination key id is required")
+	}
+	list := ListOptions{
+		TenantID:  opts.TenantID,
+		Store:     opts.Store,
+		KeyPrefix: opts.KeyPrefix,
+		Cursor:    opts.Checkpoint,
+		Limit:     opts.BatchSize,
+	}
+	return walkRecords(ctx, w.repo, list, opts.Progress, func(ctx context.Context, rec *SecretRecord) error {
+		return w.rewrapOne(ctx, rec, opts)
+	})
+}
+
+// walkRecords lists records page by page from list.Cursor and calls fn on
+// each, counting errSkipRewrap as skipped and other errors as failures.
+func walkRecords(ctx context.Context, repo SecretLister, list ListOptions, progress func(RewrapProgress),
+	fn func(context.Context, *SecretRecord) error) (*RewrapReport, error) {
+	rep := &RewrapReport{}
+	rep.Checkpoint = list.Cursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return rep, err
+		}
+		page, err := repo.ListSecrets(ctx, list)
+		if err != nil {
+			return rep, err
+		}
+		for _, rec := range page.Records {
+			if err := ctx.Err(); err != nil {
+				return rep, err
+			}
+			rep.Scanned++
+			switch err := fn(ctx, rec); {
+			case err == errSkipRewrap:
+				rep.Skipped++
+			case err != nil:
+				rep.Failed++
+				rep.Failures = append(rep.Failures, RewrapFailure{Key: rec.Key, Err: err})
+			default:
+				rep.Rewrapped++
+			}
+			rep.Checkpoint = rec.Key
+		}
+		if progress != nil {
+			progress(rep.RewrapProgress)
+		}
+		if page.NextCursor == "" {
+			return rep, nil
+		}
+		list.Cursor = page.NextCursor
+	}
+}
+
+var errSkipRewrap = errors.New("skip")
+
+func (w *Rewrapper) rewrapOne(ctx context.Context, rec *SecretRecord, opts RewrapOptions) error {
+	rewrapped := false
+	if opts.selects(rec) && isEnvelope(rec) {
+		if err := w.rewrapEnvelope(ctx, rec, opts); err != nil {
+			return err
+		}
+		rewrapped = true
+	} else if opts.selects(rec) {
+		_, encCtx, err := MakeRecordAADAndEncCtx(rec)
+		if err != nil {
+			return err
+		}
+		wrapped, err := w.keys.RewrapDEK(ctx, rec.WrappedDEK, encCtx, rec.KEKKeyID, opts.DestinationKeyID)
+		if err != nil {
+			return withKey(err, "Rewrapper.Run", rec.Key, rec.Store)
+		}
+		if err := w.repo.UpdateWrappedDEK(ctx, rec.Key, rec.WrappedDEK, wrapped, opts.DestinationKeyID); err != nil {
+			return err
+		}
+		rewrapped = true
+	}
+	n, err := w.rewrapHistory(ctx, rec, opts)
+	if err != nil {
+		return err
+	}
+	if !rewrapped && n == 0 {
+		return errSkipRewrap
+	}
+	return nil
+}
+
+// rewrapEnvelope re-wraps the DEK inside rec's Envelope, writes the envelope
+// back to rec's store and stores the new KEKKeyID (and, for SSM, the new
+// parameter version) with an UpdateSecret guarded on rec's Version and
+// ModifiedAt. When that update fails, the previous envelope is put back in
+// external stores.
+func (w *Rewrapper) rewrapEnvelope(ctx context.Context, rec *SecretRecord, opts RewrapOptions) error {
+	const op = "Rewrapper.Run"
+	sw, ok := w.repo.(SecretWriter)
+	if !ok {
+		return errorf(op, rec.Key, ErrNotSupported, "repository %T cannot update envelope records", w.repo)
+	}
+	cw, ok := w.stores[rec.Store]
+	if !ok {
+		return &Error{Op: op, Key: rec.Key, Store: rec.Store, Err: ErrUnknownStore}
+	}
+	if inv, ok := cw.(KeyInvalidator); ok {
+		inv.Invalidate(rec.Key)
+	}
+	prev, err := cw.GetCiphertext(ctx, rec)
+	if err != nil {
+		return withKey(err, op, rec.Key, rec.Store)
+	}
+	e, err := ParseEnvelope(prev)
+	if err != nil {
+		return withKey(err, op, rec.Key, rec.Store)
+	}
+	if e.KEKKeyID != rec.KEKKeyID {
+		return errorf(op, rec.Key, ErrInvalidCiphertext, "envelope kek key id %q, record has %q", e.KEKKeyID, rec.KEKKeyID)
+	}
+	_, encCtx, err := MakeRecordAADAndEncCtx(rec)
+	if err != nil {
+		return err
+	}
+	enc := base64.StdEncoding
+	wrapped, err := w.keys.RewrapDEK(ctx, enc.EncodeToString(e.WrappedDEK), encCtx, e.KEKKeyID, opts.DestinationKeyID)
+	if err != nil {
+		return withKey(err, op, rec.Key, rec.Store)
+	}
+	if e.WrappedDEK, err = enc.DecodeString(wrapped); err != nil {
+		return errorf(op, rec.Key, ErrInvalidCiphertext, "wrapped dek base64: %v", err)
+	}
+	e.KEKKeyID = opts.DestinationKeyID
+	envB64, err := e.Encode()
+	if err != nil {
+		return withKey(err, op, rec.Key, rec.Store)
+	}
+
+	next := *rec
+	next.KEKKeyID = opts.DestinationKeyID
+	next.ModifiedAt = time.Now().UTC()
+	if err := cw.PutCiphertext(ctx, &next, envB64, true); err != nil {
+		return withKey(err, op, rec.Key, rec.Store)
+	}
+	if err := sw.UpdateSecret(ctx, &next, Precondition{Version: rec.Version, ModifiedAt: rec.ModifiedAt}); err != nil {
+		if rec.Store != StoreDSVault {
+			restore := *rec
+			if rbErr := cw.PutCiphertext(ctx, &restore, prev, true); rbErr != nil {
+				err = fmt.Errorf("%w (restoring previous envelope: %v)", err, rbErr)
+			}
+		}
+		return withKey(err, op, rec.Key, rec.Store)
+	}
+	return nil
+}
+
+// rewrapHistory re-wraps the versions of rec's key that opts selects and that
+// UpdateWrappedDEK did not already cover, and returns how many it changed.
+// Each version has its own DEK and encryption context (aad_v2 binds the
+// version). Repositories without a history are left alone. Older envelope
+// versions cannot be rewritten in their store and are reported with
+// ErrNotSupported.
+func (w *Rewrapper) rewrapHistory(ctx context.Context, rec *SecretRecord, opts RewrapOptions) (int, error) {
+	const op = "Rewrapper.Run"
+	vr, ok := w.repo.(VersionRewrapRepository)
+	if !ok {
+		return 0, nil
+	}
+	versions, err := vr.ListVersions(ctx, rec.Key)
+	if errors.Is(err, ErrNotSupported) || errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, withKey(err, op, rec.Key, rec.Store)
+	}
+	n := 0
+	var stale []string
+	for _, v := range versions {
+		if !opts.selects(v) {
+			continue
+		}
+		if isEnvelope(v) {
+			stale = append(stale, v.Version)
+			continue
+		}
+		_, encCtx, err := MakeRecordAADAndEncCtx(v)
+		if err != nil {
+			return n, err
+		}
+		wrapped, err := w.keys.RewrapDEK(ctx, v.WrappedDEK, encCtx, v.KEKKeyID, opts.DestinationKeyID)
+		if err != nil {
+			return n, withKey(err, op, rec.Key, rec.Store)
+		}
+		if err := vr.UpdateVersionWrappedDEK(ctx, rec.Key, v.Version, v.WrappedDEK, wrapped, opts.DestinationKeyID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if len(stale) > 0 {
+		return n, errorf(op, rec.Key, ErrNotSupported, "envelope versions %s keep their KEK; only the current version is re-wrapped", strings.Join(stale, ", "))
+	}
+	return n, nil
+}
+
+// selects reports whether rec is still on a KEK the run moves away from.
+func (o RewrapOptions) selects(rec *SecretRecord) bool {
+	if rec.KEKKeyID == o.DestinationKeyID {
+		return false
+	}
+	return o.SourceKeyID == "" || rec.KEKKeyID == o.SourceKeyID
+}
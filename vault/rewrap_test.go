@@ -0,0 +1,162 @@
+package vault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestRewrapper_ResumesFromCheckpoint(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	_ = fakes.NewDB(t, dsn)
+	repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+	require.NoError(t, err)
+
+	kmsFake := &fakes.KMS{}
+	kmsProv := vault.NewKMSProvider(kmsFake, 1024, 5*time.Minute)
+	client := vault.NewClient(repo, kmsProv,
+		vault.NewSSMProvider(&fakes.SSM{Values: map[string]string{}}, 1024, 5*time.Minute),
+		time.Minute)
+
+	tenantID := uuid.New()
+	before := map[string]*vault.SecretRecord{}
+	for i := 0; i < 5; i++ {
+		kek := "key/old"
+		if i == 0 {
+			kek = "key/new"
+		}
+		key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+		rec, err := client.PutSecret(ctx, key, []byte("secret"), vault.PutOptions{TenantID: tenantID, KEKKeyID: kek})
+		require.NoError(t, err)
+		before[key] = rec
+	}
+	// Another tenant must not be touched.
+	otherKey := vault.MakeKey(uuid.New(), uuid.New(), string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	_, err = client.PutSecret(ctx, otherKey, []byte("x"), vault.PutOptions{TenantID: uuid.New(), KEKKeyID: "key/old"})
+	require.NoError(t, err)
+
+	rw := vault.NewRewrapper(repo, kmsProv)
+	opts := vault.RewrapOptions{
+		DestinationKeyID: "key/new",
+		TenantID:         tenantID,
+		BatchSize:        2,
+	}
+
+	// Stop after the first batch.
+	stopCtx, cancel := context.WithCancel(ctx)
+	opts.Progress = func(vault.RewrapProgress) { cancel() }
+	rep, err := rw.Run(stopCtx, opts)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 2, rep.Scanned)
+	require.NotEmpty(t, rep.Checkpoint)
+
+	opts.Progress = nil
+	opts.Checkpoint = rep.Checkpoint
+	rep2, err := rw.Run(ctx, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, rep2.Scanned)
+	require.Empty(t, rep2.Failures)
+	require.Equal(t, 4, rep.Rewrapped+rep2.Rewrapped)
+	require.Equal(t, 1, rep.Skipped+rep2.Skipped)
+	require.Equal(t, 4, kmsFake.ReEncryptCalls)
+
+	for key, old := range before {
+		got, err := repo.GetSecret(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "key/new", got.KEKKeyID)
+		require.Equal(t, old.Value, got.Value)
+		require.Equal(t, old.IV, got.IV)
+		require.Equal(t, old.Tag, got.Tag)
+		if old.KEKKeyID == "key/old" {
+			require.NotEqual(t, old.WrappedDEK, got.WrappedDEK)
+		}
+	}
+	other, err := repo.GetSecret(ctx, otherKey)
+	require.NoError(t, err)
+	require.Equal(t, "key/old", other.KEKKeyID)
+}
+
+func TestRewrapper_ReportsFailures(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := vault.NewInMemoryRepo()
+	tenantID := uuid.New()
+	for i := 0; i < 3; i++ {
+		key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+		require.NoError(t, repo.CreateSecret(ctx, &vault.SecretRecord{
+			Key: key, TenantID: tenantID, WrappedDEK: "V1JBUFBFRA==", KEKKeyID: "key/old",
+		}))
+	}
+
+	kmsFake := &fakes.KMS{ReEncryptErr: context.DeadlineExceeded}
+	rw := vault.NewRewrapper(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute))
+	rep, err := rw.Run(ctx, vault.RewrapOptions{DestinationKeyID: "key/new"})
+	require.NoError(t, err)
+	require.Equal(t, 3, rep.Failed)
+	require.Len(t, rep.Failures, 3)
+	require.ErrorIs(t, rep.Failures[0].Err, context.DeadlineExceeded)
+}
+
+func TestRewrapper_RewrapsVersionHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	_ = fakes.NewDB(t, dsn)
+	repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records", vault.WithVersionHistory())
+	require.NoError(t, err)
+
+	oldKEK, newKEK := make([]byte, 32), make([]byte, 32)
+	newKEK[0] = 1
+	both, err := vault.NewLocalKeyring(map[string][]byte{"key/old": oldKEK, "key/new": newKEK})
+	require.NoError(t, err)
+	ssmProv := vault.NewSSMProvider(&fakes.SSM{Values: map[string]string{}}, 1024, 5*time.Minute)
+	client := vault.NewClient(repo, both, ssmProv, time.Minute)
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	_, err = client.PutSecret(ctx, key, []byte("v1"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "key/old"})
+	require.NoError(t, err)
+	_, err = client.RotateSecret(ctx, key, []byte("v2"))
+	require.NoError(t, err)
+	_, err = client.RotateSecret(ctx, key, []byte("v3"))
+	require.NoError(t, err)
+
+	rep, err := vault.NewRewrapper(repo, both).Run(ctx, vault.RewrapOptions{DestinationKeyID: "key/new"})
+	require.NoError(t, err)
+	require.Empty(t, rep.Failures)
+	require.Equal(t, 1, rep.Rewrapped)
+
+	versions, err := repo.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	for _, v := range versions {
+		require.Equal(t, "key/new", v.KEKKeyID, v.Version)
+	}
+
+	// With the old KEK gone, every version still reads.
+	onlyNew, err := vault.NewLocalKeyring(map[string][]byte{"key/new": newKEK})
+	require.NoError(t, err)
+	reader := vault.NewClient(repo, onlyNew, ssmProv, time.Minute, openACL)
+	for version, want := range map[string]string{"v1": "v1", vault.VersionPrevious: "v2", vault.VersionCurrent: "v3"} {
+		pt, err := reader.GetSecretWithOptions(ctx, key, vault.ReadOptions{Version: version})
+		require.NoError(t, err, version)
+		require.Equal(t, want, string(pt), version)
+	}
+
+	// A second run finds nothing left to do.
+	rep, err = vault.NewRewrapper(repo, both).Run(ctx, vault.RewrapOptions{DestinationKeyID: "key/new"})
+	require.NoError(t, err)
+	require.Equal(t, 1, rep.Skipped)
+}
@@ -0,0 +1,126 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RotateSecret re-encrypts key with newPlaintext under a fresh DEK and bumps
+// SecretRecord.Version (v1 -> v2, ...). Consumers keep reading the same key.
+//
+// Flow on RotateSecret:
+//  1. Drop the repository's cached record and load the current one.
+//  2. Generate a new DEK under the record's KEKKeyID and encrypt with the
+//     record's DEKAlg.
+//  3. Write the ciphertext to the record's store (overwrite).
+//  4. UpdateSecret guarded by the previous Version; on failure the previous
+//     ciphertext is restored in external stores such as SSM.
+//  5. Purge the Client plaintext cache, the repository record cache, the
+//     KMSProvider DEK cache and the store's value cache (SSMProvider) for key.
+func (c *Client) RotateSecret(ctx context.Context, key string, newPlaintext []byte) (*SecretRecord, error) {
+	const op = "Client.RotateSecret"
+	w, ok := c.repo.(SecretWriter)
+	if !ok {
+		return nil, errorf(op, key, ErrNotSupported, "repository %T does not support writes", c.repo)
+	}
+	if inv, ok := c.repo.(KeyInvalidator); ok {
+		inv.Invalidate(key)
+	}
+	cur, err := c.repo.GetSecret(ctx, key)
+	if err != nil {
+		return nil, withKey(err, op, key, "")
+	}
+	if cur == nil {
+		return nil, &Error{Op: op, Key: key, Err: ErrNotFound}
+	}
+
+	next := *cur
+	next.Version, err = nextVersion(cur.Version)
+	if err != nil {
+		return nil, &Error{Op: op, Key: key, Store: cur.Store, Err: err}
+	}
+	next.ModifiedAt = time.Now().UTC()
+	if err := c.replace(ctx, w, cur, &next, newPlaintext, Precondition{Version: cur.Version}); err != nil {
+		return nil, withKey(err, op, key, cur.Store)
+	}
+	return &next, nil
+}
+
+// replace seals plaintext into next, writes its ciphertext to cur's store and
+// stores next under pre, then purges everything cached for cur. When the
+// update fails, the previous ciphertext is restored in external stores.
+func (c *Client) replace(ctx context.Context, w SecretWriter, cur, next *SecretRecord, plaintext []byte, pre Precondition) error {
+	cw, err := c.writer(cur.Store)
+	if err != nil {
+		return err
+	}
+	// External stores are overwritten in place; remember the old value so a
+	// failed UpdateSecret can put it back.
+	external := cur.Store != StoreDSVault
+	var prev string
+	if external {
+		if inv, ok := cw.(KeyInvalidator); ok {
+			inv.Invalidate(cur.Key)
+		}
+		if prev, err = cw.GetCiphertext(ctx, cur); err != nil {
+			return err
+		}
+	}
+
+	valueB64, err := c.seal(ctx, next, plaintext, isEnvelope(cur))
+	if err != nil {
+		return err
+	}
+	if err := cw.PutCiphertext(ctx, next, valueB64, true); err != nil {
+		return err
+	}
+	if err := w.UpdateSecret(ctx, next, pre); err != nil {
+		if external {
+			restore := *cur
+			if rbErr := cw.PutCiphertext(ctx, &restore, prev, true); rbErr != nil {
+				err = fmt.Errorf("%w (restoring previous ciphertext: %v)", err, rbErr)
+			}
+		}
+		return err
+	}
+
+	c.purge(cur)
+	return nil
+}
+
+// purge drops every cached artefact derived from rec: the plaintext, the
+// repository's record, the unwrapped DEK and the store's cached ciphertext.
+func (c *Client) purge(rec *SecretRecord) {
+	c.plaintextCache.Delete(rec.Key)
+	if inv, ok := c.repo.(KeyInvalidator); ok {
+		inv.Invalidate(rec.Key)
+	}
+	if inv, ok := c.keys.(dekInvalidator); ok {
+		if _, encCtx, err := MakeRecordAADAndEncCtx(rec); err == nil {
+			inv.InvalidateDEK(rec.WrappedDEK, encCtx, rec.KEKKeyID)
+		}
+	}
+	if inv, ok := c.stores[rec.Store].(KeyInvalidator); ok {
+		inv.Invalidate(rec.Key)
+	}
+}
+
+// nextVersion increments a "vN" (or bare "N") version string. An empty
+// version is treated as v0.
+func nextVersion(v string) (string, error) {
+	if v == "" {
+		return "v1", nil
+	}
+	prefix, num := "", v
+	if strings.HasPrefix(v, "v") {
+		prefix, num = "v", v[1:]
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("%w: cannot bump version %q: expected vN", ErrInvalidArgument, v)
+	}
+	return prefix + strconv.Itoa(n+1), nil
+}
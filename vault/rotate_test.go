@@ -0,0 +1,120 @@
+package vault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestClient_RotateSecret_PurgesCaches(t *testing.T) {
+	t.Parallel()
+
+	for _, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM} {
+		t.Run(string(store), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+			_ = fakes.NewDB(t, dsn)
+			repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records")
+			require.NoError(t, err)
+
+			tenantID := uuid.New()
+			key := vault.MakeKey(uuid.New(), tenantID, string(store), string(vault.EnvDev), "ds", "vault")
+			kmsFake := &fakes.KMS{}
+			ssmFake := &fakes.SSM{Values: map[string]string{}}
+			client := vault.NewClient(repo,
+				vault.NewKMSProvider(kmsFake, 1024, 5*time.Minute),
+				vault.NewSSMProvider(ssmFake, 1024, 5*time.Minute),
+				time.Minute, openACL)
+
+			_, err = client.PutSecret(ctx, key, []byte("old"), vault.PutOptions{
+				TenantID: tenantID,
+				Store:    store,
+				KEKKeyID: "alias/ds-vault",
+			})
+			require.NoError(t, err)
+
+			pt, err := client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, []byte("old"), pt)
+			require.Equal(t, 1, kmsFake.Calls)
+
+			rec, err := client.RotateSecret(ctx, key, []byte("new"))
+			require.NoError(t, err)
+			require.Equal(t, "v2", rec.Version)
+			require.Equal(t, 2, kmsFake.GenerateCalls)
+
+			// The fake hands out the same DEK and wrapped blob again, so only a
+			// purged DEK cache forces a second Decrypt.
+			pt, err = client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, []byte("new"), pt)
+			require.Equal(t, 2, kmsFake.Calls)
+
+			stored, err := repo.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, "v2", stored.Version)
+		})
+	}
+}
+
+func TestClient_RotateSecret_VersionConflict(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	repo := &racingRepo{InMemoryRepo: vault.NewInMemoryRepo()}
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	client := vault.NewClient(repo,
+		vault.NewKMSProvider(&fakes.KMS{}, 1024, 5*time.Minute),
+		vault.NewSSMProvider(ssmFake, 1024, 5*time.Minute),
+		time.Minute, openACL)
+
+	rec, err := client.PutSecret(ctx, key, []byte("old"), vault.PutOptions{
+		TenantID: tenantID,
+		Store:    vault.StoreAWSSSM,
+		KEKKeyID: "alias/ds-vault",
+	})
+	require.NoError(t, err)
+	before := ssmFake.Values[key]
+
+	repo.bumpOnUpdate = true
+	_, err = client.RotateSecret(ctx, key, []byte("new"))
+	require.Error(t, err)
+	require.Equal(t, before, ssmFake.Values[key], "previous SSM ciphertext must be restored")
+
+	// The racer bumped Version without re-sealing, which AADSchemeV2 detects.
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrAuthenticationFailed)
+
+	repo.Put(rec)
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("old"), pt)
+}
+
+// racingRepo simulates a concurrent writer bumping the version right before
+// UpdateSecret runs.
+type racingRepo struct {
+	*vault.InMemoryRepo
+	bumpOnUpdate bool
+}
+
+func (r *racingRepo) UpdateSecret(ctx context.Context, rec *vault.SecretRecord, pre vault.Precondition) error {
+	if r.bumpOnUpdate {
+		cur, _ := r.GetSecret(ctx, rec.Key)
+		bumped := *cur
+		bumped.Version = "v99"
+		r.Put(&bumped)
+	}
+	return r.InMemoryRepo.UpdateSecret(ctx, rec, pre)
+}
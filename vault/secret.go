@@ -1,6 +1,8 @@
 package vault
 
 import (
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,13 +21,20 @@ const (
 	StatusDraft     Status = "draft"
 	StatusClosed    Status = "closed"
 
-	StoreAWSSSM  Store = "aws_ssm"
-	StoreDSVault Store = "ds_vault"
+	StoreAWSSSM            Store = "aws_ssm"
+	StoreDSVault           Store = "ds_vault"
+	StoreAWSSecretsManager Store = "aws_secretsmanager"
 
 	EnvDev  Environment = "dev"
 	EnvProd Environment = "prod"
 )
 
+// Algorithm identifiers recorded in SecretRecord.DEKAlg / KEKAlg.
+const (
+	DEKAlgAES256GCM = "AES256-GCM"
+	KEKAlgAWSKMS    = "AWS-KMS"
+)
+
 type SecretRecord struct {
 	// Common
 	ID          uuid.UUID
@@ -44,14 +53,69 @@ type SecretRecord struct {
 	ModifiedBy  string
 
 	// Vault specific
-	Key        string // logical name / path (also SSM parameter name for aws_ssm)
+	Key        string // logical name / path (also SSM parameter / Secrets Manager secret name)
 	Store      Store
-	Value      string // base64 ciphertext (DB for ds_vault; empty for aws_ssm)
+	Value      string // base64 ciphertext (DB for ds_vault; empty for aws_ssm / aws_secretsmanager)
 	ACL        types.JSONB[map[string][]string]
 	IV         string // base64 nonce
 	Tag        string // base64 auth tag
 	WrappedDEK string // base64 KMS-encrypted DEK
 	KEKKeyID   string // KMS key id/arn (optional but common)
-	DEKAlg     string // e.g., AES256-GCM
+	DEKAlg     string // e.g., AES256-GCM; see Cipher
 	KEKAlg     string // e.g., AWS-KMS
+	AADScheme  string // AADSchemeV1 (or empty) or AADSchemeV2
+	Commitment string // base64 key commitment (CommittingCipher DEKAlgs only)
+	// StoreVersion pins the version of the external value holding the
+	// ciphertext, set by stores that version their writes (SSMProvider writes
+	// the parameter version). Empty reads the latest value.
+	StoreVersion string
+}
+
+// cacheSize approximates the memory held by rec for TTLCache byte bounds.
+func (rec *SecretRecord) cacheSize() int64 {
+	n := len(rec.Issuer) + len(rec.Name) + len(rec.Version) + len(rec.CreatedBy) + len(rec.ModifiedBy) +
+		len(rec.Key) + len(rec.Value) + len(rec.IV) + len(rec.Tag) + len(rec.WrappedDEK) + len(rec.KEKKeyID) +
+		len(rec.DEKAlg) + len(rec.KEKAlg) + len(rec.AADScheme) + len(rec.Commitment) + len(rec.StoreVersion)
+	if rec.OwnerID != nil {
+		n += len(*rec.OwnerID)
+	}
+	if rec.Description != nil {
+		n += len(*rec.Description)
+	}
+	for k, v := range rec.Metadata.Data {
+		n += len(k) + len(v)
+	}
+	for k, v := range rec.Tags.Data {
+		n += len(k) + len(v)
+	}
+	for k, vs := range rec.ACL.Data {
+		n += len(k)
+		for _, v := range vs {
+			n += len(v)
+		}
+	}
+	return int64(n)
+}
+
+// clone returns a deep copy of rec, so repositories never share a record
+// (or its maps) with callers.
+func (rec *SecretRecord) clone() *SecretRecord {
+	cp := *rec
+	if rec.OwnerID != nil {
+		v := *rec.OwnerID
+		cp.OwnerID = &v
+	}
+	if rec.Description != nil {
+		v := *rec.Description
+		cp.Description = &v
+	}
+	cp.Metadata.Data = maps.Clone(rec.Metadata.Data)
+	cp.Tags.Data = maps.Clone(rec.Tags.Data)
+	if rec.ACL.Data != nil {
+		cp.ACL.Data = make(map[string][]string, len(rec.ACL.Data))
+		for k, v := range rec.ACL.Data {
+			cp.ACL.Data[k] = slices.Clone(v)
+		}
+	}
+	return &cp
 }
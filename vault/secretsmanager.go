@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Metadata keys on SecretRecord that pin the Secrets Manager version read by
+// SecretsManagerProvider.GetCiphertext. Without them AWSCURRENT is read.
+const (
+	MetaSecretsManagerVersionID    = "secretsmanager_version_id"
+	MetaSecretsManagerVersionStage = "secretsmanager_version_stage"
+)
+
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// SecretsManagerProvider reads ciphertext from AWS Secrets Manager for
+// records with Store==StoreAWSSecretsManager, for ciphertexts beyond the SSM
+// parameter size limit. Register it with
+// WithCiphertextStore(StoreAWSSecretsManager, p). It is read-only.
+type SecretsManagerProvider struct {
+	sm      SecretsManagerAPI
+	cache   *TTLCache[string]
+	flights flightGroup[string]
+}
+
+func NewSecretsManagerProvider(c SecretsManagerAPI, cacheSize int, ttl time.Duration) *SecretsManagerProvider {
+	return &SecretsManagerProvider{sm: c, cache: NewTTLCache[string](cacheSize, ttl)}
+}
+
+func smCacheKey(secretID, versionID, versionStage string) string {
+	return secretID + "|" + versionID + "|" + versionStage
+}
+
+// Get returns the secret value (ciphertext base64 when store =
+// aws_secretsmanager) of secretID. versionID wins over versionStage; with
+// neither, Secrets Manager returns AWSCURRENT. A SecretBinary value is
+// returned Base64-encoded.
+func (p *SecretsManagerProvider) Get(ctx context.Context, secretID, versionID, versionStage string) (string, error) {
+	ck := smCacheKey(secretID, versionID, versionStage)
+	if v, ok := p.cache.Get(ck); ok {
+		return v, nil
+	}
+	in := &secretsmanager.GetSecretValueInput{SecretId: &secretID}
+	if versionID != "" {
+		in.VersionId = &versionID
+	} else if versionStage != "" {
+		in.VersionStage = &versionStage
+	}
+	return p.flights.Do(ctx, ck, func(ctx context.Context) (string, error) {
+		out, err := p.sm.GetSecretValue(ctx, in)
+		if err != nil {
+			return "", awsError("secretsmanager.GetSecretValue", secretID, StoreAWSSecretsManager, err)
+		}
+		var val string
+		switch {
+		case out.SecretString != nil:
+			val = *out.SecretString
+		case out.SecretBinary != nil:
+			val = base64.StdEncoding.EncodeToString(out.SecretBinary)
+		default:
+			return "", errorf("secretsmanager.GetSecretValue", secretID, ErrInvalidCiphertext, "secret has no value")
+		}
+		p.cache.Set(ck, val)
+		return val, nil
+	})
+}
+
+// Invalidate drops the cached values of secretID that are addressed by
+// staging label (or by nothing, i.e. AWSCURRENT). Values cached by
+// VersionId are immutable and kept.
+func (p *SecretsManagerProvider) Invalidate(secretID string) {
+	for _, stage := range []string{"", "AWSCURRENT", "AWSPREVIOUS", "AWSPENDING"} {
+		p.cache.Delete(smCacheKey(secretID, "", stage))
+	}
+}
+
+// Purge drops every cached value.
+func (p *SecretsManagerProvider) Purge() {
+	p.cache.Clear()
+}
+
+// GetCiphertext implements CiphertextStore: the ciphertext lives in the
+// secret named rec.Key, at the version pinned in rec.Metadata (see
+// MetaSecretsManagerVersionID / MetaSecretsManagerVersionStage).
+func (p *SecretsManagerProvider) GetCiphertext(ctx context.Context, rec *SecretRecord) (string, error) {
+	meta := rec.Metadata.Data
+	return p.Get(ctx, rec.Key, meta[MetaSecretsManagerVersionID], meta[MetaSecretsManagerVersionStage])
+}
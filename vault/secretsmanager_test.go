@@ -0,0 +1,88 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grasp-labs/ds-go-commonmodels/v2/commonmodels/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestClient_GetSecret_FromSecretsManager(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSecretsManager), string(vault.EnvProd), "ds", "vault")
+	aad, encCtx := vault.MakeAADAndEncCtx(tenantID, key)
+
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+	ivCur, ctCur, tagCur, err := fakes.EncryptWithDEK(dek, []byte("current"), aad)
+	require.NoError(t, err)
+	ivOld, ctOld, tagOld, err := fakes.EncryptWithDEK(dek, []byte("previous"), aad)
+	require.NoError(t, err)
+
+	smFake := &fakes.SecretsManager{
+		Versions: map[string]map[string]string{key: {"ver-2": ctCur, "ver-1": ctOld}},
+		Stages:   map[string]map[string]string{key: {"AWSCURRENT": "ver-2", "AWSPREVIOUS": "ver-1"}},
+	}
+	smProv := vault.NewSecretsManagerProvider(smFake, 16, time.Minute)
+
+	repo := vault.NewInMemoryRepo()
+	base := vault.SecretRecord{
+		ID: keySecretID(t, key), TenantID: tenantID, Key: key, Store: vault.StoreAWSSecretsManager, Status: vault.StatusActive,
+		WrappedDEK: "V1JBUFBFRA==", KEKKeyID: "alias/ds-vault",
+	}
+	cur := base
+	cur.IV, cur.Tag = ivCur, tagCur
+	repo.Put(&cur)
+
+	kmsFake := &fakes.KMS{Plaintext: dek, ExpectEncCtx: encCtx}
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), nil, time.Minute,
+		vault.WithCiphertextStore(vault.StoreAWSSecretsManager, smProv), openACL)
+
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("current"), pt)
+	require.Nil(t, smFake.LastInput.VersionId)
+	require.Nil(t, smFake.LastInput.VersionStage)
+
+	// A record pinned to a version id reads exactly that version.
+	pinnedKey := key + "-pinned"
+	pinned := base
+	pinned.Key = pinnedKey
+	pinned.IV, pinned.Tag = ivOld, tagOld
+	pinned.Metadata = types.JSONB[map[string]string]{Data: map[string]string{vault.MetaSecretsManagerVersionID: "ver-1"}}
+	smFake.Versions[pinnedKey] = smFake.Versions[key]
+	repo.Put(&pinned)
+
+	v, err := smProv.GetCiphertext(ctx, &pinned)
+	require.NoError(t, err)
+	require.Equal(t, ctOld, v)
+	require.Equal(t, "ver-1", *smFake.LastInput.VersionId)
+
+	v, err = smProv.Get(ctx, key, "", "AWSPREVIOUS")
+	require.NoError(t, err)
+	require.Equal(t, ctOld, v)
+
+	calls := smFake.Calls
+	_, err = smProv.Get(ctx, key, "", "AWSPREVIOUS")
+	require.NoError(t, err)
+	require.Equal(t, calls, smFake.Calls, "served from cache")
+
+	_, err = smProv.Get(ctx, "/missing", "", "")
+	require.ErrorIs(t, err, vault.ErrParameterNotFound)
+
+	// Secrets Manager is read-only for the Client.
+	_, err = client.PutSecret(ctx, key+"-new", []byte("x"), vault.PutOptions{
+		TenantID: tenantID, Store: vault.StoreAWSSecretsManager, KEKKeyID: "alias/ds-vault",
+	})
+	require.ErrorIs(t, err, vault.ErrNotSupported)
+}
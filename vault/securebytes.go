@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SecureBytes holds secret material (a plaintext or a DEK) that is
+// overwritten with zeros when it is destroyed. The SDK's caches destroy their
+// entries on eviction, expiry, Delete and Client.Close.
+//
+// When created with memory locking (see WithLockedMemory), the bytes live in
+// their own mlock'ed pages outside the Go heap so they are not swapped out;
+// where locking is unavailable (non-Linux, or RLIMIT_MEMLOCK exhausted) they
+// fall back to ordinary memory and Locked reports false.
+//
+// SecureBytes is safe for concurrent use. The zero value is an empty,
+// already-destroyed value.
+type SecureBytes struct {
+	mu     sync.RWMutex
+	b      []byte
+	locked bool
+}
+
+// NewSecureBytes copies b into a new SecureBytes. The caller keeps ownership
+// of b and should wipe it if it is no longer needed.
+func NewSecureBytes(b []byte) *SecureBytes {
+	return newSecureBytes(b, false)
+}
+
+func newSecureBytes(b []byte, lock bool) *SecureBytes {
+	s := &SecureBytes{}
+	if lock && len(b) > 0 {
+		if buf, err := lockedAlloc(len(b)); err == nil {
+			s.b, s.locked = buf, true
+		}
+	}
+	if s.b == nil {
+		s.b = make([]byte, len(b))
+	}
+	copy(s.b, b)
+	return s
+}
+
+// Bytes returns the secret itself, not a copy. The slice is only valid until
+// Destroy: it is zeroed then (and unmapped when Locked), so do not retain it
+// or hand it to code that might. Bytes returns nil once s is destroyed.
+func (s *SecureBytes) Bytes() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.b
+}
+
+// Reveal returns a copy of the secret as a string. Go strings cannot be
+// wiped, so prefer Bytes where the consumer accepts a []byte.
+func (s *SecureBytes) Reveal() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return string(s.b)
+}
+
+// redacted is what SecureBytes prints as.
+const redacted = "[REDACTED]"
+
+// String returns a redacted placeholder, never the secret, so SecureBytes
+// can be logged safely. Use Reveal or Bytes for the secret itself.
+func (s *SecureBytes) String() string { return redacted }
+
+// GoString implements fmt.GoStringer for %#v, redacted like String.
+func (s *SecureBytes) GoString() string { return "vault.SecureBytes{" + redacted + "}" }
+
+// Format implements fmt.Formatter so that no verb, %x and %q included,
+// prints the secret.
+func (s *SecureBytes) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = io.WriteString(f, s.GoString())
+		return
+	}
+	_, _ = io.WriteString(f, redacted)
+}
+
+// Len reports the length of the secret, 0 once destroyed.
+func (s *SecureBytes) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.b)
+}
+
+// Locked reports whether the secret lives in mlock'ed memory.
+func (s *SecureBytes) Locked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.locked
+}
+
+// Destroy overwrites the secret with zeros and releases it. It is safe to
+// call more than once.
+func (s *SecureBytes) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.b)
+	if s.locked {
+		lockedFree(s.b)
+	}
+	s.b, s.locked = nil, false
+}
+
+// clone returns a caller-owned copy, or false if s is already destroyed.
+func (s *SecureBytes) clone() ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.b == nil {
+		return nil, false
+	}
+	return append(make([]byte, 0, len(s.b)), s.b...), true
+}
+
+func (s *SecureBytes) cacheSize() int64 { return int64(s.Len()) }
+
+func (s *SecureBytes) evict() { s.Destroy() }
@@ -0,0 +1,109 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestSecureBytes_Destroy(t *testing.T) {
+	src := []byte("hunter2")
+	sb := vault.NewSecureBytes(src)
+	src[0] = 'X'
+	require.Equal(t, "hunter2", sb.Reveal(), "NewSecureBytes copies its input")
+	require.Equal(t, 7, sb.Len())
+
+	view := sb.Bytes()
+	sb.Destroy()
+	require.Equal(t, make([]byte, 7), view, "destroyed memory is zeroed")
+	require.Nil(t, sb.Bytes())
+	require.Equal(t, 0, sb.Len())
+	require.Equal(t, "", sb.Reveal())
+	sb.Destroy()
+}
+
+func TestSecureBytes_Redacted(t *testing.T) {
+	sb := vault.NewSecureBytes([]byte("hunter2"))
+	defer sb.Destroy()
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+		out := fmt.Sprintf(format, sb)
+		require.NotContains(t, out, "hunter2", format)
+		require.NotContains(t, out, hex.EncodeToString([]byte("hunter2")), format)
+		require.Contains(t, out, "REDACTED", format)
+	}
+	require.Equal(t, "[REDACTED]", sb.String())
+	require.Equal(t, "hunter2", sb.Reveal())
+}
+
+func TestCache_DestroysSecretsWhenTheyLeave(t *testing.T) {
+	c := vault.NewTTLCache[*vault.SecureBytes](1, time.Minute)
+	a, b, d := vault.NewSecureBytes([]byte("a")), vault.NewSecureBytes([]byte("b")), vault.NewSecureBytes([]byte("d"))
+
+	c.Set("a", a)
+	c.Set("b", b) // evicts a
+	require.Equal(t, 0, a.Len())
+
+	c.Set("b", d) // replaces b
+	require.Equal(t, 0, b.Len())
+
+	c.Delete("b")
+	require.Equal(t, 0, d.Len())
+
+	e := vault.NewSecureBytes([]byte("e"))
+	c.Set("e", e)
+	c.Clear()
+	require.Equal(t, 0, e.Len())
+
+	// Expired secrets are wiped without waiting for a Get.
+	short := vault.NewTTLCache[*vault.SecureBytes](8, 20*time.Millisecond)
+	f := vault.NewSecureBytes([]byte("f"))
+	short.Set("f", f)
+	require.Eventually(t, func() bool { return f.Len() == 0 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, 0, short.Len())
+}
+
+func TestClient_GetSecret_ReturnsDefensiveCopy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := newRefreshFixture(t)
+	kmsFake := &fakes.KMS{Plaintext: f.dek}
+	client := vault.NewClient(f.repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), nil, time.Minute,
+		vault.WithLockedMemory(), openACL)
+	defer client.Close()
+
+	pt, err := client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	copy(pt, "XXXXX")
+
+	pt, err = client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), pt, "cache hit unaffected by another caller's writes")
+	require.Equal(t, int32(1), f.repo.calls.Load())
+
+	sb, err := client.GetSecureSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, "first", sb.Reveal())
+	sb.Destroy()
+
+	pt, err = client.GetSecret(ctx, f.key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), pt, "destroying a returned SecureBytes leaves the cache intact")
+
+	// The DEK handed to the Client is wiped after use, but the cached copy in
+	// the KMSProvider is not.
+	kmsProv := vault.NewKMSProvider(kmsFake, 16, time.Minute)
+	dek, err := kmsProv.DecryptDEK(ctx, "V1JBUFBFRA==", nil, "")
+	require.NoError(t, err)
+	clear(dek)
+	dek, err = kmsProv.DecryptDEK(ctx, "V1JBUFBFRA==", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, f.dek, dek)
+}
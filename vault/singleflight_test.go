@@ -0,0 +1,251 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// gatedRepo blocks every GetSecret until gate is closed.
+type gatedRepo struct {
+	vault.SecretRepository
+	gate  chan struct{}
+	calls atomic.Int32
+}
+
+func (r *gatedRepo) GetSecret(ctx context.Context, key string) (*vault.SecretRecord, error) {
+	r.calls.Add(1)
+	<-r.gate
+	return r.SecretRepository.GetSecret(ctx, key)
+}
+
+// gatedKMS blocks every Decrypt until gate is closed and fails it if the
+// shared call's ctx was cancelled.
+type gatedKMS struct {
+	*fakes.KMS
+	gate chan struct{}
+}
+
+func (k *gatedKMS) Decrypt(ctx context.Context, in *kms.DecryptInput, opts ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	<-k.gate
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return k.KMS.Decrypt(ctx, in, opts...)
+}
+
+// gatedSSM blocks every GetParameter until gate is closed.
+type gatedSSM struct {
+	*fakes.SSM
+	gate chan struct{}
+}
+
+func (s *gatedSSM) GetParameter(ctx context.Context, in *ssm.GetParameterInput, opts ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	<-s.gate
+	return s.SSM.GetParameter(ctx, in, opts...)
+}
+
+func newGatedClient(t *testing.T) (*vault.Client, string, *gatedRepo, *fakes.KMS, chan struct{}) {
+	t.Helper()
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	aad, encCtx := vault.MakeAADAndEncCtx(tenantID, key)
+
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+	iv, ct, tag, err := fakes.EncryptWithDEK(dek, []byte("hot"), aad)
+	require.NoError(t, err)
+
+	mem := vault.NewInMemoryRepo()
+	mem.Put(&vault.SecretRecord{
+		ID: keySecretID(t, key), TenantID: tenantID, Key: key, Store: vault.StoreDSVault, Status: vault.StatusActive, Version: "v1",
+		Value: ct, IV: iv, Tag: tag, WrappedDEK: "V1JBUFBFRA==", KEKKeyID: "alias/ds-vault",
+	})
+
+	gate := make(chan struct{})
+	repo := &gatedRepo{SecretRepository: mem, gate: gate}
+	kmsFake := &fakes.KMS{Plaintext: dek, ExpectEncCtx: encCtx}
+	kmsProv := vault.NewKMSProvider(&gatedKMS{KMS: kmsFake, gate: gate}, 16, time.Minute)
+	return vault.NewClient(repo, kmsProv, nil, time.Minute, openACL), key, repo, kmsFake, gate
+}
+
+func TestClient_GetSecret_CoalescesConcurrentMisses(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, key, repo, kmsFake, gate := newGatedClient(t)
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pt, err := client.GetSecret(ctx, key)
+			if err == nil && string(pt) != "hot" {
+				err = context.DeadlineExceeded
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let every caller join the in-flight load
+	close(gate)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(1), repo.calls.Load())
+	require.Equal(t, 1, kmsFake.Calls)
+}
+
+func TestClient_GetSecret_CancelledWaiterDoesNotCancelSharedCall(t *testing.T) {
+	t.Parallel()
+	client, key, repo, kmsFake, gate := newGatedClient(t)
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.GetSecret(cancelCtx, key)
+		leaderErr <- err
+	}()
+	require.Eventually(t, func() bool { return repo.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	followerPT := make(chan []byte, 1)
+	followerErr := make(chan error, 1)
+	go func() {
+		pt, err := client.GetSecret(context.Background(), key)
+		followerPT <- pt
+		followerErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	err := <-leaderErr
+	require.ErrorIs(t, err, context.Canceled)
+	var ve *vault.Error
+	require.ErrorAs(t, err, &ve)
+	require.Equal(t, key, ve.Key)
+
+	close(gate)
+	require.NoError(t, <-followerErr)
+	require.Equal(t, []byte("hot"), <-followerPT)
+	require.Equal(t, int32(1), repo.calls.Load())
+	require.Equal(t, 1, kmsFake.Calls)
+}
+
+func TestProviders_CoalesceConcurrentMisses(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	gate := make(chan struct{})
+	kmsFake := &fakes.KMS{Plaintext: []byte("dek")}
+	kmsProv := vault.NewKMSProvider(&gatedKMS{KMS: kmsFake, gate: gate}, 16, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dek, err := kmsProv.DecryptDEK(ctx, "V1JBUFBFRA==", map[string]string{"k": "v"}, "alias/a")
+			require.NoError(t, err)
+			require.Equal(t, []byte("dek"), dek)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+	require.Equal(t, 1, kmsFake.Calls)
+
+	// Different wrapped DEKs are not coalesced.
+	_, err := kmsProv.DecryptDEK(ctx, "T1RIRVI=", map[string]string{"k": "v"}, "alias/a")
+	require.NoError(t, err)
+	require.Equal(t, 2, kmsFake.Calls)
+
+	ssmGate := make(chan struct{})
+	ssmFake := &fakes.SSM{Values: map[string]string{"/p": "v"}}
+	ssmProv := vault.NewSSMProvider(&gatedSSM{SSM: ssmFake, gate: ssmGate}, 16, time.Minute)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := ssmProv.Get(ctx, "/p")
+			require.NoError(t, err)
+			require.Equal(t, "v", v)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(ssmGate)
+	wg.Wait()
+	require.Equal(t, 1, ssmFake.Calls)
+}
+
+// recordingKMS keeps every Decrypt output so a test can check it was wiped.
+type recordingKMS struct {
+	*gatedKMS
+	mu   sync.Mutex
+	outs [][]byte
+}
+
+func (k *recordingKMS) Decrypt(ctx context.Context, in *kms.DecryptInput, opts ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	out, err := k.gatedKMS.Decrypt(ctx, in, opts...)
+	if err == nil {
+		k.mu.Lock()
+		k.outs = append(k.outs, out.Plaintext)
+		k.mu.Unlock()
+	}
+	return out, err
+}
+
+func TestKMSProvider_WipesSharedDEK(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+	gate := make(chan struct{})
+	rk := &recordingKMS{gatedKMS: &gatedKMS{KMS: &fakes.KMS{Plaintext: dek}, gate: gate}}
+	p := vault.NewKMSProvider(rk, 16, time.Minute)
+
+	const n = 8
+	got := make([][]byte, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := p.DecryptDEK(ctx, "V1JBUFBFRA==", nil, "")
+			require.NoError(t, err)
+			got[i] = d
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	for _, d := range got {
+		require.Equal(t, dek, d)
+	}
+	require.NotEmpty(t, rk.outs)
+	for _, out := range rk.outs {
+		require.Equal(t, make([]byte, 32), out, "the buffer KMS returned is wiped")
+	}
+
+	// What callers get is theirs: wiping it leaves the cached DEK intact.
+	clear(got[0])
+	again, err := p.DecryptDEK(ctx, "V1JBUFBFRA==", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, dek, again)
+}
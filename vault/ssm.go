@@ -2,19 +2,41 @@ package vault
 
 import (
 	"context"
-	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
 
+// MetaSSMParameterVersion is the SecretRecord.Metadata key that older SDK
+// versions pinned the SSM parameter version under. SSMProvider still reads it
+// for records without a StoreVersion, and Migrate (version 6) moves it to the
+// store_version column.
+//
+// Deprecated: the pin is SecretRecord.StoreVersion.
+const MetaSSMParameterVersion = "ssm_parameter_version"
+
 type SSMAPI interface {
 	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
 }
 
+// SSMWriteAPI is an SSMAPI that can also write and delete parameters.
+// SSMProvider detects it by type assertion: writes fail with ErrNotSupported
+// on a read-only client.
+type SSMWriteAPI interface {
+	SSMAPI
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	DeleteParameter(ctx context.Context, params *ssm.DeleteParameterInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error)
+}
+
+// SSMProvider reads and writes ciphertext in SSM Parameter Store and caches
+// values in a TTL cache. Concurrent Get misses for the same parameter share a
+// single GetParameter call.
 type SSMProvider struct {
-	ssm   SSMAPI
-	cache *TTLCache[string]
+	ssm     SSMAPI
+	cache   *TTLCache[string]
+	flights flightGroup[string]
 }
 
 func NewSSMProvider(c SSMAPI, cacheSize int, ttl time.Duration) *SSMProvider {
@@ -26,15 +48,76 @@ func (p *SSMProvider) Get(ctx context.Context, name string) (string, error) {
 	if v, ok := p.cache.Get(name); ok {
 		return v, nil
 	}
-	t := true
-	out, err := p.ssm.GetParameter(ctx, &ssm.GetParameterInput{
-		Name:           &name,
-		WithDecryption: &t,
+	return p.flights.Do(ctx, name, func(ctx context.Context) (string, error) {
+		t := true
+		out, err := p.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           &name,
+			WithDecryption: &t,
+		})
+		if err != nil {
+			return "", awsError("ssm.GetParameter", name, StoreAWSSSM, err)
+		}
+		val := *out.Parameter.Value
+		p.cache.Set(name, val)
+		return val, nil
+	})
+}
+
+// Invalidate drops the cached value for parameter name, if any.
+func (p *SSMProvider) Invalidate(name string) {
+	p.cache.Delete(name)
+}
+
+// Purge drops every cached value.
+func (p *SSMProvider) Purge() {
+	p.cache.Clear()
+}
+
+// Put writes value (ciphertext base64 when store = aws_ssm) to the SecureString
+// parameter name and refreshes the cache. Unless overwrite is set, SSM rejects
+// the write when the parameter already exists.
+func (p *SSMProvider) Put(ctx context.Context, name, value string, overwrite bool) error {
+	_, err := p.put(ctx, name, value, overwrite)
+	return err
+}
+
+// put is Put returning the parameter version written.
+func (p *SSMProvider) put(ctx context.Context, name, value string, overwrite bool) (int64, error) {
+	sw, ok := p.ssm.(SSMWriteAPI)
+	if !ok {
+		return 0, errorf("ssm.PutParameter", name, ErrNotSupported, "SSM client %T is read-only", p.ssm)
+	}
+	out, err := sw.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      &name,
+		Value:     &value,
+		Type:      types.ParameterTypeSecureString,
+		Overwrite: &overwrite,
 	})
 	if err != nil {
-		return "", fmt.Errorf("SSM GetParameter: %w", err)
+		return 0, awsError("ssm.PutParameter", name, StoreAWSSSM, err)
+	}
+	p.cache.Set(name, value)
+	if out.Version > 0 {
+		p.cache.Set(ssmSelector(name, out.Version), value)
+	}
+	return out.Version, nil
+}
+
+// Delete removes parameter name, with all its versions, and drops it from
+// the cache.
+func (p *SSMProvider) Delete(ctx context.Context, name string) error {
+	sw, ok := p.ssm.(SSMWriteAPI)
+	if !ok {
+		return errorf("ssm.DeleteParameter", name, ErrNotSupported, "SSM client %T is read-only", p.ssm)
+	}
+	p.cache.Delete(name)
+	if _, err := sw.DeleteParameter(ctx, &ssm.DeleteParameterInput{Name: &name}); err != nil {
+		return awsError("ssm.DeleteParameter", name, StoreAWSSSM, err)
 	}
-	val := *out.Parameter.Value
-	p.cache.Set(name, val)
-	return val, nil
+	return nil
+}
+
+// ssmSelector addresses version of parameter name ("name:version").
+func ssmSelector(name string, version int64) string {
+	return name + ":" + strconv.FormatInt(version, 10)
 }
@@ -0,0 +1,81 @@
+package vault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestClient_GetSecret_EnforcesStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		status vault.Status
+		want   error
+	}{
+		{vault.StatusDeleted, vault.ErrSecretDeleted},
+		{vault.StatusSuspended, vault.ErrSecretSuspended},
+		{vault.StatusRejected, vault.ErrSecretRejected},
+		{vault.StatusDraft, vault.ErrSecretDraft},
+		{vault.StatusClosed, vault.ErrSecretClosed},
+		{"archived", vault.ErrSecretInactive},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.status), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			tenantID := uuid.New()
+			key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+			kmsFake := &fakes.KMS{}
+			client := vault.NewClient(vault.NewInMemoryRepo(),
+				vault.NewKMSProvider(kmsFake, 16, time.Minute),
+				vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute),
+				time.Minute, openACL)
+
+			_, err := client.PutSecret(ctx, key, []byte("secret"), vault.PutOptions{
+				TenantID: tenantID,
+				KEKKeyID: "alias/ds-vault",
+				Status:   tc.status,
+			})
+			require.NoError(t, err)
+
+			_, err = client.GetSecret(ctx, key)
+			require.ErrorIs(t, err, tc.want)
+			require.Equal(t, 0, kmsFake.Calls, "no KMS call for a rejected record")
+		})
+	}
+}
+
+func TestClient_GetSecretWithOptions_AllowDraft(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	client := vault.NewClient(vault.NewInMemoryRepo(),
+		vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute),
+		vault.NewSSMProvider(&fakes.SSM{}, 16, time.Minute),
+		time.Minute, openACL)
+
+	_, err := client.PutSecret(ctx, key, []byte("draft"), vault.PutOptions{
+		TenantID: tenantID,
+		KEKKeyID: "alias/ds-vault",
+		Status:   vault.StatusDraft,
+	})
+	require.NoError(t, err)
+
+	pt, err := client.GetSecretWithOptions(ctx, key, vault.ReadOptions{AllowDraft: true})
+	require.NoError(t, err)
+	require.Equal(t, []byte("draft"), pt)
+
+	// The draft read must not leak into the cache used by normal reads.
+	_, err = client.GetSecret(ctx, key)
+	require.ErrorIs(t, err, vault.ErrSecretDraft)
+}
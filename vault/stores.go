@@ -0,0 +1,126 @@
+package vault
+
+import (
+	"context"
+	"strconv"
+)
+
+// CiphertextStore returns Base64(ciphertext) for a record from wherever its
+// SecretRecord.Store keeps it. Client dispatches on rec.Store to the store
+// registered for it: the DB (StoreDSVault) and SSMProvider (StoreAWSSSM) are
+// built in, more can be added with WithCiphertextStore.
+type CiphertextStore interface {
+	GetCiphertext(ctx context.Context, rec *SecretRecord) (string, error)
+}
+
+// CiphertextWriter is a CiphertextStore that Client.PutSecret and
+// Client.RotateSecret can write to. PutCiphertext stores valueB64 for rec and
+// updates rec.Value accordingly (the ciphertext itself for the DB, empty for
+// external stores). Unless overwrite is set it must not replace an existing
+// value.
+type CiphertextWriter interface {
+	CiphertextStore
+	PutCiphertext(ctx context.Context, rec *SecretRecord, valueB64 string, overwrite bool) error
+}
+
+// CiphertextDeleter is a CiphertextWriter that can remove what PutCiphertext
+// wrote. Client.PutSecret uses it to roll back the ciphertext of a record
+// whose insert failed, so a retry does not find it already present.
+type CiphertextDeleter interface {
+	CiphertextWriter
+	DeleteCiphertext(ctx context.Context, rec *SecretRecord) error
+}
+
+// VersionedCiphertextStore is a CiphertextStore that keeps earlier values
+// too. Reads of a specific version (ReadOptions.Version) go through
+// GetCiphertextVersion, which returns the ciphertext of rec's version rather
+// than the latest one. Stores that keep the ciphertext in the record itself,
+// such as the DB, need not implement it.
+type VersionedCiphertextStore interface {
+	CiphertextStore
+	GetCiphertextVersion(ctx context.Context, rec *SecretRecord) (string, error)
+}
+
+// versionedStore reads a VersionedCiphertextStore through GetCiphertext.
+type versionedStore struct{ VersionedCiphertextStore }
+
+func (s versionedStore) GetCiphertext(ctx context.Context, rec *SecretRecord) (string, error) {
+	return s.GetCiphertextVersion(ctx, rec)
+}
+
+// WithCiphertextStore registers cs for records whose Store is store,
+// replacing any built-in store for it.
+func WithCiphertextStore(store Store, cs CiphertextStore) ClientOption {
+	return func(c *Client) { c.stores[store] = cs }
+}
+
+// dbStore keeps the ciphertext in SecretRecord.Value (StoreDSVault).
+type dbStore struct{}
+
+func (dbStore) GetCiphertext(_ context.Context, rec *SecretRecord) (string, error) {
+	return rec.Value, nil
+}
+
+func (dbStore) PutCiphertext(_ context.Context, rec *SecretRecord, valueB64 string, _ bool) error {
+	rec.Value = valueB64
+	return nil
+}
+
+// GetCiphertext implements CiphertextStore: the ciphertext lives in the
+// parameter named rec.Key.
+func (p *SSMProvider) GetCiphertext(ctx context.Context, rec *SecretRecord) (string, error) {
+	return p.Get(ctx, rec.Key)
+}
+
+// GetCiphertextVersion implements VersionedCiphertextStore: it reads the
+// parameter version pinned in rec.StoreVersion, or the latest value if none
+// is pinned.
+func (p *SSMProvider) GetCiphertextVersion(ctx context.Context, rec *SecretRecord) (string, error) {
+	v := rec.StoreVersion
+	if v == "" {
+		v = rec.Metadata.Data[MetaSSMParameterVersion] // pinned by an older SDK
+	}
+	if v != "" {
+		return p.Get(ctx, rec.Key+":"+v)
+	}
+	return p.Get(ctx, rec.Key)
+}
+
+// PutCiphertext implements CiphertextWriter. It pins the parameter version
+// it wrote in rec.StoreVersion.
+func (p *SSMProvider) PutCiphertext(ctx context.Context, rec *SecretRecord, valueB64 string, overwrite bool) error {
+	rec.Value = ""
+	version, err := p.put(ctx, rec.Key, valueB64, overwrite)
+	if err != nil || version == 0 {
+		return err
+	}
+	rec.StoreVersion = strconv.FormatInt(version, 10)
+	return nil
+}
+
+// DeleteCiphertext implements CiphertextDeleter: it deletes the parameter
+// named rec.Key.
+func (p *SSMProvider) DeleteCiphertext(ctx context.Context, rec *SecretRecord) error {
+	return p.Delete(ctx, rec.Key)
+}
+
+// store returns the CiphertextStore registered for s.
+func (c *Client) store(s Store) (CiphertextStore, error) {
+	if cs, ok := c.stores[s]; ok {
+		return cs, nil
+	}
+	return nil, &Error{Op: "Client.store", Store: s, Err: ErrUnknownStore}
+}
+
+// writer returns the CiphertextWriter registered for s.
+func (c *Client) writer(s Store) (CiphertextWriter, error) {
+	cs, err := c.store(s)
+	if err != nil {
+		return nil, err
+	}
+	w, ok := cs.(CiphertextWriter)
+	if !ok {
+		return nil, errorf("Client.store", "", ErrNotSupported, "store %q (%T) is read-only", s, cs)
+	}
+	return w, nil
+}
@@ -0,0 +1,120 @@
+package vault_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+// mapStore is a writable CiphertextStore keyed by secret key.
+type mapStore struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+func (s *mapStore) GetCiphertext(_ context.Context, rec *vault.SecretRecord) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[rec.Key], nil
+}
+
+func (s *mapStore) PutCiphertext(_ context.Context, rec *vault.SecretRecord, v string, _ bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.Value = ""
+	s.m[rec.Key] = v
+	return nil
+}
+
+func TestClient_WithoutSSM(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	kmsFake := &fakes.KMS{}
+	repo := vault.NewInMemoryRepo()
+	client := vault.NewClient(repo, vault.NewKMSProvider(kmsFake, 16, time.Minute), nil, time.Minute, openACL)
+
+	dbKey := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreDSVault), string(vault.EnvDev), "ds", "vault")
+	_, err := client.PutSecret(ctx, dbKey, []byte("db-only"), vault.PutOptions{TenantID: tenantID, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+	pt, err := client.GetSecret(ctx, dbKey)
+	require.NoError(t, err)
+	require.Equal(t, []byte("db-only"), pt)
+
+	ssmKey := vault.MakeKey(uuid.New(), tenantID, string(vault.StoreAWSSSM), string(vault.EnvDev), "ds", "vault")
+	_, err = client.PutSecret(ctx, ssmKey, []byte("x"), vault.PutOptions{TenantID: tenantID, Store: vault.StoreAWSSSM, KEKKeyID: "alias/ds-vault"})
+	require.ErrorIs(t, err, vault.ErrUnknownStore)
+
+	// An unregistered store never falls back to rec.Value.
+	require.NoError(t, repo.CreateSecret(ctx, &vault.SecretRecord{
+		ID: keySecretID(t, ssmKey), Key: ssmKey, TenantID: tenantID, Store: vault.StoreAWSSSM, Status: vault.StatusActive,
+		Value: "c3RhbGU=", WrappedDEK: "V1JBUFBFRA==",
+	}))
+	calls := kmsFake.Calls
+	_, err = client.GetSecret(ctx, ssmKey)
+	require.ErrorIs(t, err, vault.ErrUnknownStore)
+	require.Equal(t, calls, kmsFake.Calls)
+}
+
+func TestClient_WithCiphertextStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	const storeFile vault.Store = "file_blob"
+	blobs := &mapStore{m: map[string]string{}}
+	tenantID := uuid.New()
+	key := vault.MakeKey(uuid.New(), tenantID, string(storeFile), string(vault.EnvDev), "ds", "vault")
+
+	client := vault.NewClient(vault.NewInMemoryRepo(),
+		vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute,
+		vault.WithCiphertextStore(storeFile, blobs), openACL)
+
+	rec, err := client.PutSecret(ctx, key, []byte("custom"), vault.PutOptions{TenantID: tenantID, Store: storeFile, KEKKeyID: "alias/ds-vault"})
+	require.NoError(t, err)
+	require.Empty(t, rec.Value)
+	require.NotEmpty(t, blobs.m[key])
+
+	pt, err := client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("custom"), pt)
+
+	_, err = client.RotateSecret(ctx, key, []byte("custom-2"))
+	require.NoError(t, err)
+	pt, err = client.GetSecret(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("custom-2"), pt)
+}
+
+func TestSSMProvider_PinsParameterVersion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ssmFake := &fakes.SSM{Values: map[string]string{}}
+	p := vault.NewSSMProvider(ssmFake, 16, time.Minute)
+
+	rec := &vault.SecretRecord{Key: "/ds/vault/param"}
+	require.NoError(t, p.PutCiphertext(ctx, rec, "first", false))
+	require.Equal(t, "1", rec.StoreVersion)
+	require.Nil(t, rec.Metadata.Data, "metadata is not touched")
+	next := &vault.SecretRecord{Key: rec.Key}
+	require.NoError(t, p.PutCiphertext(ctx, next, "second", true))
+	require.Equal(t, "2", next.StoreVersion)
+
+	got, err := p.GetCiphertextVersion(ctx, rec)
+	require.NoError(t, err)
+	require.Equal(t, "first", got)
+
+	// Records pinned by older SDKs keep the version in metadata.
+	legacy := &vault.SecretRecord{Key: rec.Key}
+	legacy.Metadata.Data = map[string]string{vault.MetaSSMParameterVersion: "1"}
+	got, err = p.GetCiphertextVersion(ctx, legacy)
+	require.NoError(t, err)
+	require.Equal(t, "first", got)
+}
@@ -100,7 +100,7 @@ func TestGetSecret_AWSSSM_Envelope(t *testing.T) {
 	// Providers + client
 	kmsProv := vault.NewKMSProvider(kmsFake, 1024, 5*time.Minute)
 	ssmProv := vault.NewSSMProvider(ssm, 1024, 5*time.Minute)
-	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute)
+	client := vault.NewClient(repo, kmsProv, ssmProv, time.Minute, openACL)
 
 	// --- Act
 	got, err := client.GetSecret(ctx, key)
@@ -0,0 +1,70 @@
+package vault
+
+import "context"
+
+// Version aliases accepted by SecretVersioner.GetSecretVersion and
+// ReadOptions.Version.
+const (
+	// VersionCurrent is the record's current version, i.e. GetSecret.
+	VersionCurrent = "current"
+	// VersionPrevious is the version written before the current one. During
+	// a rotation, consumers whose new credential is rejected can fall back
+	// to it.
+	VersionPrevious = "previous"
+)
+
+// SecretVersioner is implemented by repositories that keep a version history
+// of each record. Every version keeps its own ciphertext, IV, Tag and
+// WrappedDEK, so any of them can still be decrypted after a rotation.
+// PostgresSecretRepository, PgxSecretRepository and InMemoryRepo implement
+// it.
+type SecretVersioner interface {
+	// GetSecretVersion returns version of key: a SecretRecord.Version, or
+	// VersionCurrent or VersionPrevious. It fails with ErrNotFound when the
+	// key or the version does not exist.
+	GetSecretVersion(ctx context.Context, key, version string) (*SecretRecord, error)
+	// ListVersions returns every version of key, oldest first.
+	ListVersions(ctx context.Context, key string) ([]*SecretRecord, error)
+}
+
+// getSecretVersion is GetSecretWithOptions for opts.Version. Historic
+// versions bypass the plaintext cache, which only holds current versions.
+func (c *Client) getSecretVersion(ctx context.Context, key string, opts ReadOptions) ([]byte, error) {
+	const op = "Client.GetSecret"
+	v, ok := c.repo.(SecretVersioner)
+	if !ok {
+		return nil, errorf(op, key, ErrNotSupported, "repository %T has no version history", c.repo)
+	}
+	rec, err := v.GetSecretVersion(ctx, key, opts.Version)
+	if err == nil && rec == nil {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, withKey(err, op, key, "")
+	}
+	if err := c.checkKey(key, rec); err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	// Authorize first: the status of a record is not for unauthorized callers.
+	if err := c.authorize(ctx, PermissionRead, rec); err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	if err := statusErr(rec.Status, opts.AllowDraft); err != nil {
+		return nil, &Error{Op: op, Key: key, Store: rec.Store, Err: err}
+	}
+	cs, err := c.store(rec.Store)
+	if err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	if vcs, ok := cs.(VersionedCiphertextStore); ok {
+		cs = versionedStore{vcs}
+	}
+	// Not shared with current reads, which must populate the cache.
+	pt, err := c.opens.Do(ctx, key+"|"+rec.Version+"|history", func(ctx context.Context) ([]byte, error) {
+		return c.open(ctx, cs, rec, false)
+	})
+	if err != nil {
+		return nil, withKey(err, op, key, rec.Store)
+	}
+	return pt, nil
+}
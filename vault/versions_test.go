@@ -0,0 +1,93 @@
+package vault_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/grasp-labs/ds-vault-go-sdk/internal/fakes"
+	vault "github.com/grasp-labs/ds-vault-go-sdk/vault"
+)
+
+func TestClient_ReadPreviousVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, store := range []vault.Store{vault.StoreDSVault, vault.StoreAWSSSM} {
+		t.Run(string(store), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+			_ = fakes.NewDB(t, dsn)
+			repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records", vault.WithVersionHistory())
+			require.NoError(t, err)
+
+			tenantID := uuid.New()
+			key := vault.MakeKey(uuid.New(), tenantID, string(store), string(vault.EnvDev), "ds", "vault")
+			client := vault.NewClient(repo,
+				vault.NewKMSProvider(&fakes.KMS{}, 1024, 5*time.Minute),
+				vault.NewSSMProvider(&fakes.SSM{Values: map[string]string{}}, 1024, 5*time.Minute),
+				time.Minute, openACL)
+
+			_, err = client.PutSecret(ctx, key, []byte("old"), vault.PutOptions{TenantID: tenantID, Store: store, KEKKeyID: "alias/ds-vault"})
+			require.NoError(t, err)
+			_, err = client.GetSecretWithOptions(ctx, key, vault.ReadOptions{Version: vault.VersionPrevious})
+			require.ErrorIs(t, err, vault.ErrNotFound)
+
+			_, err = client.RotateSecret(ctx, key, []byte("new"))
+			require.NoError(t, err)
+
+			for version, want := range map[string]string{
+				"":                    "new",
+				vault.VersionCurrent:  "new",
+				vault.VersionPrevious: "old",
+				"v1":                  "old",
+				"v2":                  "new",
+			} {
+				pt, err := client.GetSecretWithOptions(ctx, key, vault.ReadOptions{Version: version})
+				require.NoError(t, err, version)
+				require.Equal(t, want, string(pt), version)
+			}
+
+			// Reading an old version does not replace the cached current one.
+			pt, err := client.GetSecret(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, "new", string(pt))
+		})
+	}
+}
+
+func TestClient_ReadVersion_NotSupported(t *testing.T) {
+	t.Parallel()
+	client := vault.NewClient(&stubRepo{}, vault.NewKMSProvider(&fakes.KMS{}, 16, time.Minute), nil, time.Minute)
+	_, err := client.GetSecretWithOptions(context.Background(), "/ds/vault/a", vault.ReadOptions{Version: vault.VersionPrevious})
+	require.ErrorIs(t, err, vault.ErrNotSupported)
+}
+
+func TestRepository_VersionHistoryIsOptIn(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// A deployment whose table has no <table>_versions yet.
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db := fakes.NewDB(t, dsn)
+	require.NoError(t, db.Exec("DROP TABLE secret_records_versions").Error)
+
+	repo, err := vault.NewGormSecretRepository(sqlite.Open(dsn), "secret_records", vault.WithoutCache())
+	require.NoError(t, err)
+	rec := &vault.SecretRecord{ID: uuid.New(), TenantID: uuid.New(), Key: "svc/a", Version: "v1", Status: vault.StatusActive}
+	require.NoError(t, repo.CreateSecret(ctx, rec))
+	next := *rec
+	next.Version = "v2"
+	require.NoError(t, repo.UpdateSecret(ctx, &next, vault.Precondition{Version: "v1"}))
+
+	_, err = repo.ListVersions(ctx, "svc/a")
+	require.ErrorIs(t, err, vault.ErrNotSupported)
+	got, err := repo.GetSecretVersion(ctx, "svc/a", vault.VersionCurrent)
+	require.NoError(t, err)
+	require.Equal(t, "v2", got.Version)
+}